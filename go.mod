@@ -5,6 +5,7 @@ go 1.23
 require (
 	fyne.io/fyne v1.4.3
 	fyne.io/fyne/v2 v2.5.2
+	github.com/BurntSushi/toml v1.4.0
 	github.com/spf13/cobra v1.8.1
 	golang.org/x/sys v0.20.0
 	golang.org/x/term v0.20.0
@@ -12,7 +13,6 @@ require (
 
 require (
 	fyne.io/systray v1.11.0 // indirect
-	github.com/BurntSushi/toml v1.4.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fredbi/uri v1.1.0 // indirect
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
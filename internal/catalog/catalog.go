@@ -0,0 +1,435 @@
+// Package catalog loads the requirements from TOML catalogs. The default
+// catalog is embedded, the catalogs of the administrator and the user and one
+// given explicitly override it.
+package catalog
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+//go:embed default.toml
+var defaultCatalog string
+
+// defaultSource names the embedded catalog in errors.
+const defaultSource = "default catalog"
+
+// managers are the names of all backends. Native names, variants and
+// repositories are keyed by them.
+var managers = []string{
+	"apk", "apt", "brew", "choco", "dnf", "flatpak", "nix", "nixpkgs",
+	"pacman", "rpm-ostree", "snap", "toolbox", "user", "zypper",
+}
+
+// platformPattern matches the keys of archives: "any", an operating system
+// like "linux" or a platform like "linux/amd64".
+var platformPattern = regexp.MustCompile(`^[a-z0-9]+(/[a-z0-9]+)?$`)
+
+type file struct {
+	Repositories map[string]*repository `toml:"repositories"`
+	Requirements []*requirement         `toml:"requirements"`
+
+	// sources names the catalogs merged into the file.
+	sources []string
+}
+
+type repository struct {
+	Name        string   `toml:"name"`
+	Description string   `toml:"description"`
+	URL         string   `toml:"url"`
+	KeyURL      string   `toml:"key_url"`
+	KeyID       string   `toml:"key_id"`
+	Suite       string   `toml:"suite"`
+	Components  []string `toml:"components"`
+
+	source string
+}
+
+type requirement struct {
+	Name        string `toml:"name"`
+	Description string `toml:"description"`
+	Optional    bool   `toml:"optional"`
+	// Disabled drops a requirement of an earlier catalog.
+	Disabled   bool     `toml:"disabled"`
+	Constraint string   `toml:"constraint"`
+	Requires   []string `toml:"requires"`
+	spec
+	// Alternatives are packages that satisfy the requirement as well, in
+	// order of preference after the one of the requirement itself.
+	Alternatives []*alternative `toml:"alternatives"`
+	Probes       []*probe       `toml:"probes"`
+
+	source string
+}
+
+type alternative struct {
+	Name string `toml:"name"`
+	spec
+}
+
+// spec describes where a package comes from, for a requirement and for its
+// alternatives.
+type spec struct {
+	Library      bool                `toml:"library"`
+	Managers     []string            `toml:"managers"`
+	Channel      string              `toml:"channel"`
+	Names        map[string]string   `toml:"names"`
+	Variants     []*variant          `toml:"variants"`
+	Repositories map[string]string   `toml:"repositories"`
+	Archives     map[string]*archive `toml:"archives"`
+}
+
+type variant struct {
+	Manager  string `toml:"manager"`
+	OS       string `toml:"os"`
+	Versions string `toml:"versions"`
+	// Name is a pointer to tell a missing name from the empty one, which
+	// marks the package as missing.
+	Name *string `toml:"name"`
+}
+
+type probe struct {
+	Binary         string   `toml:"binary"`
+	Dirs           []string `toml:"dirs"`
+	VersionArgs    []string `toml:"version_args"`
+	VersionPattern string   `toml:"version_pattern"`
+}
+
+type archive struct {
+	Version      string `toml:"version"`
+	URL          string `toml:"url"`
+	Bin          string `toml:"bin"`
+	SHA256       string `toml:"sha256"`
+	ChecksumURL  string `toml:"checksum_url"`
+	SignatureURL string `toml:"signature_url"`
+	KeyURL       string `toml:"key_url"`
+}
+
+// Paths returns the catalogs that override the default one, in order: the
+// one of the administrator and the one of the user if they exist, then
+// explicit if it is not empty.
+func Paths(explicit string) []string {
+	var candidates []string
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("ProgramData"); dir != "" {
+			candidates = append(candidates, filepath.Join(dir, "jws", "catalog.toml"))
+		}
+	} else {
+		candidates = append(candidates, "/etc/jws/catalog.toml")
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "jws", "catalog.toml"))
+	}
+
+	var paths []string
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if explicit != "" {
+		paths = append(paths, explicit)
+	}
+	return paths
+}
+
+// Load returns the packages of the default catalog overridden by the catalogs
+// at paths, in catalog order. A requirement replaces the one with the same
+// name from an earlier catalog, and so does a repository. All problems found
+// are reported together.
+func Load(paths ...string) ([]*packagemanager.Package, error) {
+	merged, err := parse(defaultSource, defaultCatalog)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		overlay, err := parse(path, string(data))
+		if err != nil {
+			return nil, err
+		}
+		merged.merge(overlay)
+	}
+	return merged.packages()
+}
+
+// parse decodes a single catalog. Unknown keys are rejected, as they are
+// mostly typos that would otherwise be ignored silently.
+func parse(source string, data string) (*file, error) {
+	f := file{sources: []string{source}}
+	meta, err := toml.Decode(data, &f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	var errs []error
+	for _, key := range meta.Undecoded() {
+		errs = append(errs, fmt.Errorf("%s: unknown key %s", source, key))
+	}
+	seen := map[string]bool{}
+	for i, req := range f.Requirements {
+		req.source = source
+		if req.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: requirement %d has no name", source, i+1))
+			continue
+		}
+		if seen[req.Name] {
+			errs = append(errs, fmt.Errorf("%s: requirement %s is defined twice", source, req.Name))
+		}
+		seen[req.Name] = true
+	}
+	for _, repo := range f.Repositories {
+		repo.source = source
+	}
+	return &f, errors.Join(errs...)
+}
+
+// merge applies overlay to f. Replaced requirements keep their position,
+// disabled ones are dropped.
+func (f *file) merge(overlay *file) {
+	f.sources = append(f.sources, overlay.sources...)
+	if f.Repositories == nil {
+		f.Repositories = map[string]*repository{}
+	}
+	for key, repo := range overlay.Repositories {
+		f.Repositories[key] = repo
+	}
+
+	for _, req := range overlay.Requirements {
+		i := slices.IndexFunc(f.Requirements, func(r *requirement) bool { return r.Name == req.Name })
+		if i < 0 {
+			f.Requirements = append(f.Requirements, req)
+		} else {
+			f.Requirements[i] = req
+		}
+	}
+	f.Requirements = slices.DeleteFunc(f.Requirements, func(r *requirement) bool { return r.Disabled })
+}
+
+// packages validates f and converts it. Requirements with the same repository
+// share the Repository. Requirements come after the ones they require.
+func (f *file) packages() ([]*packagemanager.Package, error) {
+	var errs []error
+	repositories := map[string]*packagemanager.Repository{}
+	for _, key := range slices.Sorted(maps.Keys(f.Repositories)) {
+		repo := f.Repositories[key]
+		if repo.Name == "" || repo.URL == "" {
+			errs = append(errs, fmt.Errorf("%s: repository %s needs a name and a url", repo.source, key))
+			continue
+		}
+		repositories[key] = &packagemanager.Repository{
+			Name:        repo.Name,
+			Description: repo.Description,
+			URL:         repo.URL,
+			KeyURL:      repo.KeyURL,
+			KeyID:       repo.KeyID,
+			Suite:       repo.Suite,
+			Components:  repo.Components,
+		}
+	}
+
+	names := map[string]bool{}
+	for _, req := range f.Requirements {
+		names[req.Name] = true
+	}
+	var pkgs []*packagemanager.Package
+	for _, req := range f.Requirements {
+		pkg, problems := req.pkg(repositories, names)
+		for _, problem := range problems {
+			errs = append(errs, fmt.Errorf("%s: requirement %s: %s", req.source, req.Name, problem))
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	pkgs, err := packagemanager.SortByRequires(pkgs)
+	if err != nil {
+		// A cycle can span several catalogs.
+		return nil, fmt.Errorf("%s: %w", strings.Join(f.sources, ", "), err)
+	}
+	return pkgs, nil
+}
+
+// pkg converts r and returns the problems found with it.
+func (r *requirement) pkg(repositories map[string]*packagemanager.Repository, names map[string]bool) (*packagemanager.Package, []string) {
+	var problems []string
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if r.Constraint != "" {
+		if _, err := version.ParseConstraint(r.Constraint); err != nil {
+			problem("constraint: %v", err)
+		}
+	}
+	for _, name := range r.Requires {
+		if !names[name] {
+			problem("requires unknown requirement %s", name)
+		}
+	}
+
+	pkg := r.spec.pkg(r.Name, repositories, problem)
+	pkg.Description = r.Description
+	pkg.Constraint = r.Constraint
+	pkg.Requires = r.Requires
+	pkg.Optional = r.Optional
+
+	seen := map[string]bool{r.Name: true}
+	for i, a := range r.Alternatives {
+		if a.Name == "" || seen[a.Name] {
+			problem("alternative %d needs a name of its own", i+1)
+		}
+		seen[a.Name] = true
+		alternative := a.spec.pkg(a.Name, repositories, func(format string, args ...any) {
+			problem("alternative %s: %s", a.Name, fmt.Sprintf(format, args...))
+		})
+		// An alternative has to fulfill the requirement the same way.
+		alternative.Description = r.Description
+		alternative.Constraint = r.Constraint
+		alternative.Requires = r.Requires
+		alternative.Optional = r.Optional
+		pkg.Alternatives = append(pkg.Alternatives, alternative)
+	}
+
+	for i, p := range r.Probes {
+		if p.Binary == "" || strings.ContainsAny(p.Binary, `/\`) {
+			problem("probe %d needs a binary name without directory", i+1)
+		}
+		if (len(p.VersionArgs) == 0) != (p.VersionPattern == "") {
+			problem("probe %d needs both version_args and version_pattern or neither", i+1)
+		}
+		if p.VersionPattern != "" {
+			if pattern, err := regexp.Compile(p.VersionPattern); err != nil {
+				problem("probe %d: version_pattern: %v", i+1, err)
+			} else if pattern.NumSubexp() == 0 {
+				problem("probe %d: version_pattern needs a group around the version", i+1)
+			}
+		}
+		pkg.Probes = append(pkg.Probes, &packagemanager.Probe{
+			Binary:         p.Binary,
+			Dirs:           p.Dirs,
+			VersionArgs:    p.VersionArgs,
+			VersionPattern: p.VersionPattern,
+		})
+	}
+
+	return pkg, problems
+}
+
+// pkg converts s into a package with the given name and reports the problems
+// found with it to problem.
+func (s *spec) pkg(name string, repositories map[string]*packagemanager.Repository, problem func(format string, args ...any)) *packagemanager.Package {
+	checkManager := func(field string, manager string) {
+		if !slices.Contains(managers, manager) {
+			problem("%s: unknown package manager %q", field, manager)
+		}
+	}
+	// Backends pass names on as arguments, where a leading dash would make
+	// them options.
+	checkName := func(field string, name string) {
+		if strings.HasPrefix(name, "-") {
+			problem("%s: package name %q must not start with -", field, name)
+		}
+	}
+
+	if len(s.Names) == 0 && len(s.Archives) == 0 {
+		problem("neither names nor archives")
+	}
+	for _, manager := range slices.Sorted(maps.Keys(s.Names)) {
+		checkManager("names", manager)
+		checkName("names", s.Names[manager])
+	}
+	for _, manager := range s.Managers {
+		checkManager("managers", manager)
+	}
+
+	pkg := &packagemanager.Package{
+		Name:              name,
+		NativePackageName: s.Names,
+		Managers:          s.Managers,
+		Channel:           s.Channel,
+		SystemPackage:     true,
+		Library:           s.Library,
+	}
+
+	for i, v := range s.Variants {
+		checkManager(fmt.Sprintf("variant %d", i+1), v.Manager)
+		if v.Versions != "" {
+			if _, err := version.ParseConstraint(v.Versions); err != nil {
+				problem("variant %d: versions: %v", i+1, err)
+			}
+			if v.OS == "" {
+				problem("variant %d: versions need an os", i+1)
+			}
+		}
+		if v.Name == nil {
+			problem(`variant %d has no name, name = "" marks the package as missing`, i+1)
+			continue
+		}
+		checkName(fmt.Sprintf("variant %d", i+1), *v.Name)
+		pkg.Variants = append(pkg.Variants, &packagemanager.Variant{
+			Manager:  v.Manager,
+			OS:       v.OS,
+			Versions: v.Versions,
+			Name:     *v.Name,
+		})
+	}
+
+	if len(s.Repositories) > 0 {
+		pkg.Repositories = map[string]*packagemanager.Repository{}
+	}
+	for _, manager := range slices.Sorted(maps.Keys(s.Repositories)) {
+		key := s.Repositories[manager]
+		checkManager("repositories", manager)
+		repo, ok := repositories[key]
+		if !ok {
+			problem("unknown repository %s", key)
+			continue
+		}
+		pkg.Repositories[manager] = repo
+	}
+
+	if len(s.Archives) > 0 {
+		pkg.Archives = map[string]*packagemanager.Archive{}
+	}
+	for _, platform := range slices.Sorted(maps.Keys(s.Archives)) {
+		a := s.Archives[platform]
+		if !platformPattern.MatchString(platform) {
+			problem("archive %s: platform must look like any, linux or linux/amd64", platform)
+		}
+		if a.URL == "" || a.Version == "" {
+			problem("archive %s needs a version and a url", platform)
+		}
+		if a.SHA256 == "" && a.ChecksumURL == "" && (a.SignatureURL == "" || a.KeyURL == "") {
+			problem("archive %s cannot be verified, it needs sha256, checksum_url or signature_url and key_url", platform)
+		}
+		pkg.Archives[platform] = &packagemanager.Archive{
+			Version:      a.Version,
+			URL:          a.URL,
+			Bin:          a.Bin,
+			SHA256:       a.SHA256,
+			ChecksumURL:  a.ChecksumURL,
+			SignatureURL: a.SignatureURL,
+			KeyURL:       a.KeyURL,
+		}
+	}
+
+	return pkg
+}
@@ -0,0 +1,200 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+)
+
+func writeCatalog(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "catalog.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func find(pkgs []*packagemanager.Package, name string) *packagemanager.Package {
+	for _, pkg := range pkgs {
+		if pkg.Name == name {
+			return pkg
+		}
+	}
+	return nil
+}
+
+func TestLoadDefault(t *testing.T) {
+	pkgs, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+		if !pkg.SystemPackage {
+			t.Errorf("%s is no system package", pkg.Name)
+		}
+	}
+	if got, want := strings.Join(names, " "), "git openjdk maven gradle podman podman-compose vscode gcc"; got != want {
+		t.Errorf("Load() = %s, want %s", got, want)
+	}
+
+	openjdk := find(pkgs, "openjdk")
+	if openjdk.Constraint != ">= 17" || len(openjdk.Variants) != 5 || openjdk.Variants[0].Name != "" {
+		t.Errorf("openjdk = %+v", openjdk)
+	}
+	var alternatives []string
+	for _, alternative := range openjdk.Alternatives {
+		alternatives = append(alternatives, alternative.Name)
+		if alternative.Constraint != openjdk.Constraint || !alternative.SystemPackage {
+			t.Errorf("alternative %s = %+v, want the constraint of openjdk", alternative.Name, alternative)
+		}
+	}
+	if got, want := strings.Join(alternatives, " "), "openjdk-21 default-jdk temurin corretto"; got != want {
+		t.Errorf("openjdk alternatives = %s, want %s", got, want)
+	}
+	if len(openjdk.Probes) != 1 || openjdk.Probes[0].Binary != "java" {
+		t.Errorf("openjdk probes = %+v", openjdk.Probes)
+	}
+	if archive := openjdk.Archives["darwin/arm64"]; archive == nil || archive.Bin != "Contents/Home/bin" {
+		t.Errorf("openjdk archive for darwin/arm64 = %+v", archive)
+	}
+
+	vscode := find(pkgs, "vscode")
+	if !vscode.Optional || vscode.Managers[0] != "flatpak" {
+		t.Errorf("vscode = %+v", vscode)
+	}
+	if vscode.Repositories["dnf"] != vscode.Repositories["zypper"] || vscode.Repositories["dnf"].KeyID != "be1229cf" {
+		t.Errorf("vscode repositories = %+v", vscode.Repositories)
+	}
+}
+
+func TestLoadOverride(t *testing.T) {
+	admin := writeCatalog(t, `
+[[requirements]]
+name = "podman"
+disabled = true
+
+[[requirements]]
+name = "podman-compose"
+disabled = true
+
+[[requirements]]
+name = "maven"
+description = "Maven vom Fachbereich"
+[requirements.names]
+apt = "maven"
+
+[[requirements]]
+name = "postgresql"
+description = "Datenbank"
+[requirements.names]
+apt = "postgresql"
+dnf = "postgresql-server"
+`)
+	user := writeCatalog(t, `
+[repositories.flathub]
+name = "flathub"
+url = "https://example.org/flathub.flatpakrepo"
+
+[[requirements]]
+name = "gcc"
+disabled = true
+`)
+
+	pkgs, err := Load(admin, user)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	if got, want := strings.Join(names, " "), "git openjdk maven gradle vscode postgresql"; got != want {
+		t.Errorf("Load() = %s, want %s", got, want)
+	}
+	if maven := find(pkgs, "maven"); maven.Description != "Maven vom Fachbereich" || len(maven.NativePackageName) != 1 || maven.Archives != nil {
+		t.Errorf("maven = %+v", maven)
+	}
+	if url := find(pkgs, "vscode").Repositories["flatpak"].URL; url != "https://example.org/flathub.flatpakrepo" {
+		t.Errorf("flathub = %s", url)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		catalog string
+		want    []string
+	}{
+		{"[[requirements]\nname = \"x\"", []string{"toml"}},
+		{"[[requirements]]\nname = \"x\"\nnmaes = {apt = \"x\"}\n", []string{"unknown key requirements.nmaes"}},
+		{"[[requirements]]\ndescription = \"x\"\n", []string{"requirement 1 has no name"}},
+		{"[[requirements]]\nname = \"x\"\nnames = {apt = \"x\"}\n[[requirements]]\nname = \"x\"\nnames = {apt = \"x\"}\n", []string{"requirement x is defined twice"}},
+		{"[[requirements]]\nname = \"x\"\nconstraint = \">=\"\nnames = {atp = \"x\"}\n", []string{"requirement x: constraint:", `requirement x: names: unknown package manager "atp"`}},
+		{"[[requirements]]\nname = \"x\"\nnames = {apt = \"x\"}\nvariants = [{manager = \"apt\", versions = \">= 12\"}]\n", []string{"variant 1: versions need an os", "variant 1 has no name"}},
+		{"[[requirements]]\nname = \"x\"\nnames = {brew = \"--force\"}\nvariants = [{manager = \"apt\", name = \"-y\"}]\n", []string{`names: package name "--force" must not start with -`, `variant 1: package name "-y" must not start with -`}},
+		{"[[requirements]]\nname = \"x\"\nnames = {apt = \"x\"}\nrepositories = {apt = \"missing\"}\n", []string{"requirement x: unknown repository missing"}},
+		{"[[requirements]]\nname = \"x\"\nnames = {apt = \"x\"}\nrequires = [\"jdk\"]\n", []string{"requirement x: requires unknown requirement jdk"}},
+		{"[[requirements]]\nname = \"openjdk\"\nnames = {apt = \"x\"}\nrequires = [\"maven\"]\n", []string{"cycle in requires: openjdk -> maven -> openjdk"}},
+		{"[[requirements]]\nname = \"x\"\nnames = {apt = \"x\"}\nprobes = [{binary = \"bin/x\", version_pattern = \"x (\\\\S+\"}]\n", []string{"probe 1 needs a binary name", "probe 1 needs both version_args and version_pattern", "probe 1: version_pattern:"}},
+		{"[[requirements]]\nname = \"x\"\nnames = {apt = \"x\"}\n[[requirements.alternatives]]\nname = \"x\"\nnames = {atp = \"y\"}\n", []string{"alternative 1 needs a name of its own", `alternative x: names: unknown package manager "atp"`}},
+		{"[repositories.r]\nurl = \"https://example.org\"\n", []string{"repository r needs a name and a url"}},
+		{"[[requirements]]\nname = \"x\"\n[requirements.archives.\"Linux-x64\"]\nversion = \"1\"\nurl = \"https://example.org/x.tar.gz\"\n", []string{"archive Linux-x64: platform must look like", "archive Linux-x64 cannot be verified"}},
+	}
+
+	for _, tt := range tests {
+		path := writeCatalog(t, tt.catalog)
+		_, err := Load(path)
+		if err == nil {
+			t.Errorf("Load(%q) succeeded, want error", tt.catalog)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Load(%q) error = %v, want %q", tt.catalog, err, want)
+			}
+		}
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("Load(%q) error = %v, want the path of the catalog", tt.catalog, err)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Error("Load() of a missing catalog succeeded, want error")
+	}
+}
+
+func TestDefaultCoversManagers(t *testing.T) {
+	pkgs, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// rpm-ostree and toolbox install the packages of dnf, the directory of
+	// the user takes release archives.
+	borrowed := map[string]string{"rpm-ostree": "dnf", "toolbox": "dnf"}
+	for _, manager := range managers {
+		key := manager
+		if borrowed[manager] != "" {
+			key = borrowed[manager]
+		}
+
+		covered := false
+		for _, pkg := range pkgs {
+			for _, choice := range pkg.Choices() {
+				if choice.NativePackageName[key] != "" || manager == "user" && len(choice.Archives) > 0 {
+					covered = true
+				}
+			}
+		}
+		if !covered {
+			t.Errorf("the default catalog has no packages for %s", manager)
+		}
+	}
+}
@@ -1,45 +1,219 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
+	"github.com/PatrykHegenberg/jws_gui/internal/gui"
 	"github.com/PatrykHegenberg/jws_gui/internal/platform"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
 	"github.com/spf13/cobra"
 )
 
-func SetupCLI(pm *platform.PlatformManager) *cobra.Command {
+// SetupCLI returns the root command, which starts the GUI if no subcommand is
+// given. The platform manager is created once the flags are parsed, and only
+// for the commands that work with it, not for help and completion.
+func SetupCLI() *cobra.Command {
+	var options platform.Options
+	var pm *platform.PlatformManager
+	withManager := func(run func(cmd *cobra.Command, args []string)) func(cmd *cobra.Command, args []string) {
+		return func(cmd *cobra.Command, args []string) {
+			// Only the root command starts the GUI.
+			options.Graphical = !cmd.HasParent()
+			pm = platform.NewPlatformManager(cmd.Context(), runner.NewExecRunner(), options)
+			run(cmd, args)
+		}
+	}
+
 	rootCmd := &cobra.Command{
 		Use:   "uni-project-starter",
 		Short: "Universitäts-Projekt-Starter-Anwendung",
+		Args:  cobra.NoArgs,
+		Run: withManager(func(cmd *cobra.Command, args []string) {
+			// Ctrl+C in the terminal ends the GUI instead of cancelling
+			// operations.
+			gui.SetupGUI(context.WithoutCancel(cmd.Context()), pm)
+		}),
 	}
+	rootCmd.PersistentFlags().BoolVar(&options.Rootless, "rootless", false,
+		"Installiert ohne Administratorrechte in das Benutzerverzeichnis")
+	rootCmd.PersistentFlags().StringVar(&options.ArchiveCache, "archive-cache", "",
+		"Verzeichnis für heruntergeladene Archive, kann vorab befüllt werden")
+	rootCmd.PersistentFlags().StringVar(&options.Manager, "manager", os.Getenv("JWS_PACKAGE_MANAGER"),
+		"Zu verwendender Paketmanager, z. B. dnf oder flatpak (auch über JWS_PACKAGE_MANAGER)")
+	rootCmd.PersistentFlags().StringVar(&options.Catalog, "catalog", os.Getenv("JWS_CATALOG"),
+		"Paketkatalog, der die Standardanforderungen ergänzt oder ersetzt (auch über JWS_CATALOG)")
+	rootCmd.PersistentFlags().BoolVar(&options.SystemInstallation, "system-installation", false,
+		"Installiert Flatpaks für alle Benutzer statt nur für den aktuellen")
+	rootCmd.PersistentFlags().StringVar(&options.Elevator, "elevator", os.Getenv("JWS_ELEVATOR"),
+		fmt.Sprintf("Programm für Administratorrechte: auto, %s (auch über JWS_ELEVATOR)", strings.Join(platform.Elevators, ", ")))
 
 	checkCmd := &cobra.Command{
 		Use:   "check",
 		Short: "Überprüft Systemanforderungen",
-		Run: func(cmd *cobra.Command, args []string) {
+		Run: withManager(func(cmd *cobra.Command, args []string) {
 			fmt.Printf("Erkannter Paketmanager: %s\n", pm.PackageManager.Name())
+			for manager := pm.PackageManager; ; {
+				combined, ok := manager.(*packagemanager.Combined)
+				if !ok {
+					break
+				}
+				if combined.PreferSecondary || routedTo(pm, combined.Secondary) {
+					fmt.Printf("Zusätzlicher Paketmanager: %s\n", combined.Secondary.Name())
+				}
+				manager = combined.Primary
+			}
+			if pm.Rootless {
+				fmt.Println("Modus: ohne Administratorrechte")
+			} else if pm.Elevator != nil {
+				fmt.Printf("Administratorrechte über: %s\n", pm.Elevator.Name())
+			}
+			if pm.OS.OSTree {
+				fmt.Println("System: unveränderliches Image (rpm-ostree)")
+			}
+			for _, manager := range pm.Managers {
+				fmt.Printf("Weiterer Paketmanager: %s\n", manager.Name())
+			}
 
 			for _, req := range pm.Requirements {
-				status := "nicht installiert"
-				if req.Installed {
-					status = "installiert"
+				var details []string
+				switch {
+				case req.Status == platform.StatusExternal:
+					details = append(details, req.Location)
+					if req.Version != "" {
+						details = append(details, "Version "+req.Version)
+					}
+				case req.Package.Name != req.Name:
+					// An alternative fulfills the requirement.
+					details = append(details, req.Package.Name)
 				}
-				fmt.Printf("%s: %s\n", req.Name, status)
+				switch {
+				case req.Status == platform.StatusTooOld:
+					details = append(details, req.Version, fmt.Sprintf("benötigt %s", req.Constraint))
+				case req.Status != platform.StatusExternal && req.Manager != pm.PackageManager:
+					details = append(details, req.Manager.Name())
+				}
+
+				if len(details) > 0 {
+					fmt.Printf("%s: %s (%s)\n", req.Name, req.Status, strings.Join(details, ", "))
+				} else {
+					fmt.Printf("%s: %s\n", req.Name, req.Status)
+				}
+			}
+
+			if missing := pm.MissingRequirements(); len(missing) > 0 {
+				fmt.Println("Schritte zur Installation:")
+				for _, plan := range pm.NewInstallPlans(missing) {
+					for _, step := range plan.Steps(pm.Elevator) {
+						fmt.Printf("  %s\n", step)
+					}
+				}
+			}
+			for _, hint := range pm.Hints() {
+				fmt.Println(hint)
 			}
-		},
+		}),
 	}
 
 	installCmd := &cobra.Command{
 		Use:   "install",
 		Short: "Installiert fehlende Systemanforderungen",
-		Run: func(cmd *cobra.Command, args []string) {
-			if err := pm.CheckAndInstallRequirements(false, nil); err != nil {
-				log.Fatalf("Fehler bei der Installation: %v", err)
+		Run: withManager(func(cmd *cobra.Command, args []string) {
+			if err := pm.CheckAndInstallRequirements(cmd.Context(), false, nil); err != nil {
+				exitOnError("Fehler bei der Installation", err)
 			}
-		},
+		}),
 	}
 
-	rootCmd.AddCommand(checkCmd, installCmd)
+	uninstallCmd := &cobra.Command{
+		Use:   "uninstall <anforderung>",
+		Short: "Entfernt eine installierte Systemanforderung",
+		Args:  cobra.ExactArgs(1),
+		Run: withManager(func(cmd *cobra.Command, args []string) {
+			if err := pm.RemoveRequirement(cmd.Context(), args[0], false, nil); err != nil {
+				exitOnError("Fehler bei der Deinstallation", err)
+			}
+		}),
+	}
+
+	devshellCmd := &cobra.Command{
+		Use:   "devshell [verzeichnis]",
+		Short: "Erzeugt eine flake.nix mit einer Entwicklungsumgebung statt zu installieren",
+		Args:  cobra.MaximumNArgs(1),
+		Run: withManager(func(cmd *cobra.Command, args []string) {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			path, err := pm.WriteDevShell(dir)
+			if err != nil {
+				log.Fatalf("Fehler beim Erzeugen der Entwicklungsumgebung: %v", err)
+			}
+			fmt.Printf("%s geschrieben, die Umgebung startet mit: nix develop\n", path)
+		}),
+	}
+
+	outdatedCmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "Listet Systemanforderungen mit verfügbaren Updates",
+		Run: withManager(func(cmd *cobra.Command, args []string) {
+			outdated := pm.OutdatedRequirements()
+			if len(outdated) == 0 {
+				fmt.Println("Alle Pakete sind aktuell.")
+				return
+			}
+
+			for _, req := range outdated {
+				fmt.Printf("%s: %s -> %s\n", req.Name, req.Version, req.Candidate)
+			}
+		}),
+	}
+
+	upgradeCmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Aktualisiert veraltete Systemanforderungen",
+		Run: withManager(func(cmd *cobra.Command, args []string) {
+			if err := pm.UpgradeRequirements(cmd.Context(), false, nil); err != nil {
+				exitOnError("Fehler bei der Aktualisierung", err)
+			}
+		}),
+	}
+
+	rootCmd.AddCommand(checkCmd, installCmd, uninstallCmd, outdatedCmd, upgradeCmd, devshellCmd)
 	return rootCmd
 }
+
+// routedTo reports whether the package manager of pm hands any requirement on
+// to backend.
+func routedTo(pm *platform.PlatformManager, backend packagemanager.PackageManager) bool {
+	for _, req := range pm.Requirements {
+		manager := req.Manager
+		for manager != backend {
+			router, ok := manager.(packagemanager.Router)
+			if !ok {
+				break
+			}
+			manager = router.Backend(req.Package)
+		}
+		if manager == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// exitOnError ends the program with err. A cancel by the user is not reported
+// as an error, what did not run was already listed, and the exit code is the
+// one shells use after Ctrl+C.
+func exitOnError(message string, err error) {
+	if errors.Is(err, platform.ErrCancelled) {
+		fmt.Println("Abgebrochen.")
+		os.Exit(130)
+	}
+	log.Fatalf("%s: %v", message, err)
+}
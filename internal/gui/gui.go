@@ -1,6 +1,8 @@
 package gui
 
 import (
+	"context"
+	"fmt"
 	"log"
 
 	"fyne.io/fyne/theme"
@@ -13,35 +15,84 @@ import (
 	"github.com/PatrykHegenberg/jws_gui/internal/platform"
 )
 
-func createDependencyList(pm *platform.PlatformManager) *widget.List {
+func createDependencyList(ctx context.Context, pm *platform.PlatformManager, window fyne.Window) *widget.List {
 	list := widget.NewList(
 		func() int { return len(pm.Requirements) },
 		func() fyne.CanvasObject {
-			return container.NewHBox(
+			return container.NewBorder(nil, nil,
 				widget.NewIcon(theme.ConfirmIcon()),
+				widget.NewButtonWithIcon("Entfernen", theme.DeleteIcon(), nil),
 				widget.NewLabel("Template"),
 			)
 		},
 		func(id widget.ListItemID, item fyne.CanvasObject) {
 			req := pm.Requirements[id]
 			box := item.(*fyne.Container)
-			icon := box.Objects[0].(*widget.Icon)
-			label := box.Objects[1].(*widget.Label)
-
-			label.SetText(req.Name)
-			req.InstalledBind.AddListener(binding.NewDataListener(func() {
-				installed, _ := req.InstalledBind.Get()
-				if installed {
-					icon.SetResource(theme.ConfirmIcon())
-				} else {
-					icon.SetResource(theme.CancelIcon())
+			label := box.Objects[0].(*widget.Label)
+			icon := box.Objects[1].(*widget.Icon)
+			remove := box.Objects[2].(*widget.Button)
+
+			remove.OnTapped = func() {
+				if err := pm.RemoveRequirement(ctx, req.Name, true, window); err != nil {
+					dialog.ShowError(err, window)
 				}
-			}))
+			}
+			switch req.Status {
+			case platform.StatusOutdated:
+				icon.SetResource(theme.DownloadIcon())
+				label.SetText(fmt.Sprintf("%s (Update verfügbar: %s)", title(req), req.Candidate))
+			case platform.StatusTooOld:
+				icon.SetResource(theme.WarningIcon())
+				label.SetText(fmt.Sprintf("%s (%s installiert, benötigt %s)", title(req), req.Version, req.Constraint))
+			case platform.StatusRebootRequired:
+				icon.SetResource(theme.ViewRefreshIcon())
+				label.SetText(fmt.Sprintf("%s (Neustart erforderlich)", title(req)))
+			case platform.StatusExternal:
+				icon.SetResource(theme.ConfirmIcon())
+				label.SetText(fmt.Sprintf("%s (gefunden in %s)", title(req), req.Location))
+			case platform.StatusUnknown:
+				icon.SetResource(theme.QuestionIcon())
+				label.SetText(fmt.Sprintf("%s (Status unbekannt)", title(req)))
+			case platform.StatusInstalled:
+				icon.SetResource(theme.ConfirmIcon())
+				label.SetText(title(req))
+			default:
+				icon.SetResource(theme.CancelIcon())
+				label.SetText(title(req))
+			}
+			if req.Status.Installed() {
+				remove.Show()
+			} else {
+				remove.Hide()
+			}
 		},
 	)
+
+	// Rows are reused for other requirements, so they are only updated by
+	// refreshing the list.
+	refresh := binding.NewDataListener(list.Refresh)
+	for _, req := range pm.Requirements {
+		req.StatusBind.AddListener(refresh)
+	}
 	return list
 }
 
+// title names req in the list, with the alternative that fulfills it, its
+// description and whether it is optional.
+func title(req *platform.SoftwareRequirement) string {
+	text := req.Name
+	if req.Package.Name != req.Name && req.Status != platform.StatusExternal {
+		text += ": " + req.Package.Name
+	}
+	if req.Package.Description != "" {
+		text += " – " + req.Package.Description
+	}
+	if req.Package.Optional {
+		text += " (optional)"
+	}
+	return text
+}
+
 func createProjectBox(pm *platform.PlatformManager) *fyne.Container {
 	return container.NewVBox(
 		widget.NewButton("Basic JakartaEE with Servlet and DB", func() {
@@ -73,20 +124,20 @@ func createTitle() *fyne.Container {
 	return container.NewCenter(titleLabel)
 }
 
-func SetupGUI(pm *platform.PlatformManager) {
+func SetupGUI(ctx context.Context, pm *platform.PlatformManager) {
 	myApp := app.New()
 	myWindow := myApp.NewWindow("Uni Project Starter")
 
 	titleContainer := createTitle()
 
-	list := createDependencyList(pm)
+	list := createDependencyList(ctx, pm, myWindow)
 
 	updateList := func() {
 		list.Refresh()
 	}
 
 	installButton := widget.NewButton("Fehlende Pakete installieren", func() {
-		err := pm.CheckAndInstallRequirements(true, myWindow)
+		err := pm.CheckAndInstallRequirements(ctx, true, myWindow)
 		if err != nil {
 			dialog.ShowError(err, myWindow)
 		}
@@ -94,7 +145,27 @@ func SetupGUI(pm *platform.PlatformManager) {
 		updateList()
 	})
 
-	packageBox := container.NewBorder(nil, installButton, nil, nil, list)
+	upgradeButton := widget.NewButton("Updates installieren", func() {
+		err := pm.UpgradeRequirements(ctx, true, myWindow)
+		if err != nil {
+			dialog.ShowError(err, myWindow)
+		}
+		updateList()
+	})
+
+	// The output of installations stays available once their dialogs are
+	// closed.
+	logButton := widget.NewButton("Protokoll anzeigen", func() {
+		pm.ShowLog(myWindow)
+	})
+	logButton.Hide()
+	pm.Log.Lines.AddListener(binding.NewDataListener(func() {
+		if !pm.Log.Empty() {
+			logButton.Show()
+		}
+	}))
+
+	packageBox := container.NewBorder(nil, container.NewGridWithColumns(3, installButton, upgradeButton, logButton), nil, nil, list)
 	projectsBox := createProjectBox(pm)
 
 	content := container.NewBorder(
@@ -112,18 +183,25 @@ func SetupGUI(pm *platform.PlatformManager) {
 	)
 
 	updateList()
-	pm.AllInstalled.AddListener(binding.NewDataListener(func() {
+	// Projects only need the required packages, optional ones can still be
+	// installed afterwards.
+	updateButtons := binding.NewDataListener(func() {
 		allInstalled, _ := pm.AllInstalled.Get()
 		if allInstalled {
-			list.Hide()
-			installButton.Hide()
 			projectsBox.Show()
 		} else {
-			list.Show()
-			installButton.Show()
 			projectsBox.Hide()
 		}
-	}))
+		if len(pm.MissingRequirements()) > 0 {
+			installButton.Show()
+		} else {
+			installButton.Hide()
+		}
+	})
+	pm.AllInstalled.AddListener(updateButtons)
+	for _, req := range pm.Requirements {
+		req.StatusBind.AddListener(updateButtons)
+	}
 	myWindow.SetContent(content)
 
 	myWindow.Resize(fyne.NewSize(800, 400))
@@ -0,0 +1,47 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+)
+
+// WriteDevShell writes a flake.nix into dir whose development shell provides
+// all requirements, as an alternative to installing them. It returns the path
+// of the file. An existing flake.nix is never overwritten.
+func (pm *PlatformManager) WriteDevShell(dir string) (string, error) {
+	// The shell gets the first choice of every requirement that nix has.
+	var pkgs []*packagemanager.Package
+	for _, pkg := range pm.Packages {
+		i := slices.IndexFunc(pkg.Choices(), func(choice *packagemanager.Package) bool {
+			return choice.NativePackageName["nix"] != ""
+		})
+		if i >= 0 {
+			pkgs = append(pkgs, pkg.Choices()[i])
+		}
+	}
+
+	flake, err := packagemanager.DevShellFlake(pkgs)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, "flake.nix")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return "", fmt.Errorf("%s existiert bereits", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.WriteString(flake); err != nil {
+		file.Close()
+		return "", err
+	}
+	return path, file.Close()
+}
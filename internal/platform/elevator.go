@@ -0,0 +1,181 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+// Elevator runs commands that need root privileges as root.
+type Elevator interface {
+	// Name is the name of the elevator as passed to --elevator.
+	Name() string
+	// Wrap returns the command that runs cmd as root.
+	Wrap(cmd runner.Command) runner.Command
+	// NeedsPassword reports whether the password of the user has to be
+	// passed to the wrapped commands on stdin right now. Elevators that ask
+	// for it on their own report false.
+	NeedsPassword(ctx context.Context) bool
+}
+
+// Elevators are the names accepted by --elevator besides "auto".
+var Elevators = []string{"sudo", "doas", "pkexec", "run0", "none"}
+
+// geteuid is replaced by tests, which may run as root.
+var geteuid = os.Geteuid
+
+var errNoElevator = errors.New("Kein Programm für Administratorrechte gefunden (sudo, doas, pkexec oder run0)")
+
+// detectElevator returns the elevator called name, or detects one if name is
+// empty or "auto": none if the process already runs as root, sudo or doas if
+// they work without a password, and otherwise the first one installed. The
+// GUI prefers pkexec, which asks for the password with a dialog of the
+// desktop. It returns nil if no elevator is installed.
+func detectElevator(ctx context.Context, r runner.Runner, name string, graphical bool) (Elevator, error) {
+	if name != "" && name != "auto" {
+		elevator := newElevator(name, r)
+		if elevator == nil {
+			return nil, fmt.Errorf("Unbekanntes Programm für Administratorrechte %s, möglich sind auto, %s", name, strings.Join(Elevators, ", "))
+		}
+		if _, err := r.LookPath(name); err != nil && name != "none" {
+			return nil, fmt.Errorf("%s nicht gefunden", name)
+		}
+		return elevator, nil
+	}
+
+	if geteuid() == 0 {
+		return none{}, nil
+	}
+	if passwordless(ctx, r, "sudo") {
+		return sudo{r}, nil
+	}
+	if passwordless(ctx, r, "doas") {
+		return doas{nopass: true}, nil
+	}
+
+	order := []string{"sudo", "doas", "run0", "pkexec"}
+	if graphical {
+		// doas needs a terminal to ask for the password.
+		order = []string{"pkexec", "sudo", "run0", "doas"}
+	}
+	for _, name := range order {
+		if _, err := r.LookPath(name); err == nil {
+			return newElevator(name, r), nil
+		}
+	}
+	return nil, nil
+}
+
+func newElevator(name string, r runner.Runner) Elevator {
+	switch name {
+	case "sudo":
+		return sudo{r}
+	case "doas":
+		return doas{}
+	case "pkexec":
+		return pkexec{}
+	case "run0":
+		return run0{}
+	case "none":
+		return none{}
+	}
+	return nil
+}
+
+// passwordless reports whether the elevator called name runs commands without
+// asking for a password, because it is configured that way or still remembers
+// the password.
+func passwordless(ctx context.Context, r runner.Runner, name string) bool {
+	if _, err := r.LookPath(name); err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	_, _, err := runner.Output(ctx, r, runner.Command{Name: name, Args: []string{"-n", "true"}})
+	return err == nil
+}
+
+// withEnv returns the arguments that run cmd with its environment through env,
+// as the elevators reset the environment.
+func withEnv(cmd runner.Command) []string {
+	var args []string
+	if len(cmd.Env) > 0 {
+		args = append(args, "env")
+		args = append(args, cmd.Env...)
+	}
+	args = append(args, cmd.Name)
+	return append(args, cmd.Args...)
+}
+
+// sudo reads the password from stdin unless it remembers it or is configured
+// with NOPASSWD.
+type sudo struct {
+	r runner.Runner
+}
+
+func (sudo) Name() string { return "sudo" }
+
+func (sudo) Wrap(cmd runner.Command) runner.Command {
+	return runner.Command{Name: "sudo", Args: append([]string{"-S", "--"}, withEnv(cmd)...)}
+}
+
+func (s sudo) NeedsPassword(ctx context.Context) bool {
+	return !passwordless(ctx, s.r, "sudo")
+}
+
+// doas asks for the password on the terminal itself and cannot read it from
+// stdin.
+type doas struct {
+	nopass bool
+}
+
+func (doas) Name() string { return "doas" }
+
+func (d doas) Wrap(cmd runner.Command) runner.Command {
+	if d.nopass {
+		return runner.Command{Name: "doas", Args: append([]string{"-n", "--"}, withEnv(cmd)...)}
+	}
+	return runner.Command{Name: "doas", Args: append([]string{"--"}, withEnv(cmd)...), Interactive: true}
+}
+
+func (doas) NeedsPassword(context.Context) bool { return false }
+
+// pkexec lets polkit ask for the password, with a dialog of the desktop or on
+// the terminal.
+type pkexec struct{}
+
+func (pkexec) Name() string { return "pkexec" }
+
+func (pkexec) Wrap(cmd runner.Command) runner.Command {
+	// pkexec takes no "--", the command comes right after its options.
+	return runner.Command{Name: "pkexec", Args: withEnv(cmd), Interactive: true}
+}
+
+func (pkexec) NeedsPassword(context.Context) bool { return false }
+
+// run0 of systemd lets polkit ask for the password like pkexec.
+type run0 struct{}
+
+func (run0) Name() string { return "run0" }
+
+func (run0) Wrap(cmd runner.Command) runner.Command {
+	return runner.Command{Name: "run0", Args: append([]string{"--"}, withEnv(cmd)...), Interactive: true}
+}
+
+func (run0) NeedsPassword(context.Context) bool { return false }
+
+// none runs commands as they are, for a process that already runs as root.
+type none struct{}
+
+func (none) Name() string { return "none" }
+
+func (none) Wrap(cmd runner.Command) runner.Command {
+	cmd.Elevate = false
+	return cmd
+}
+
+func (none) NeedsPassword(context.Context) bool { return false }
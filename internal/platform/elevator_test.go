@@ -0,0 +1,127 @@
+//go:build !windows
+
+package platform
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+func TestElevatorWrap(t *testing.T) {
+	cmd := runner.Command{
+		Name:    "apt",
+		Args:    []string{"install", "-y", "--", "git"},
+		Env:     []string{"DEBIAN_FRONTEND=noninteractive"},
+		Elevate: true,
+	}
+	tests := []struct {
+		elevator    Elevator
+		want        string
+		interactive bool
+	}{
+		{sudo{}, "sudo -S -- env DEBIAN_FRONTEND=noninteractive apt install -y -- git", false},
+		{doas{}, "doas -- env DEBIAN_FRONTEND=noninteractive apt install -y -- git", true},
+		{doas{nopass: true}, "doas -n -- env DEBIAN_FRONTEND=noninteractive apt install -y -- git", false},
+		{pkexec{}, "pkexec env DEBIAN_FRONTEND=noninteractive apt install -y -- git", true},
+		{run0{}, "run0 -- env DEBIAN_FRONTEND=noninteractive apt install -y -- git", true},
+		{none{}, "DEBIAN_FRONTEND=noninteractive apt install -y -- git", false},
+	}
+	for _, tt := range tests {
+		got := tt.elevator.Wrap(cmd)
+		if got.Shell() != tt.want || got.Interactive != tt.interactive || got.Elevate {
+			t.Errorf("%s: Wrap() = %#v, want %s with interactive %v", tt.elevator.Name(), got, tt.want, tt.interactive)
+		}
+	}
+
+	// Without variables the command is run directly.
+	plain := runner.Command{Name: "dnf", Args: []string{"install", "-y", "--", "git"}, Elevate: true}
+	if got, want := (pkexec{}).Wrap(plain).Shell(), "pkexec dnf install -y -- git"; got != want {
+		t.Errorf("pkexec: Wrap() = %s, want %s", got, want)
+	}
+}
+
+// fakeElevators lets fake find the elevators called names.
+func fakeElevators(fake *runner.Fake, names ...string) {
+	for _, name := range names {
+		fake.Paths[name] = "/usr/bin/" + name
+	}
+}
+
+func TestDetectElevator(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed []string
+		// passwordless holds the elevators that work without a password.
+		passwordless []string
+		root         bool
+		elevator     string
+		graphical    bool
+		want         Elevator
+		wantErr      bool
+	}{
+		{name: "root", installed: []string{"sudo"}, root: true, want: none{}},
+		{name: "sudo remembers the password", installed: []string{"sudo", "doas", "pkexec"}, passwordless: []string{"sudo"}, graphical: true, want: sudo{}},
+		{name: "doas without password", installed: []string{"sudo", "doas"}, passwordless: []string{"doas"}, want: doas{nopass: true}},
+		{name: "terminal", installed: []string{"sudo", "doas", "pkexec", "run0"}, want: sudo{}},
+		{name: "gui", installed: []string{"sudo", "doas", "pkexec", "run0"}, graphical: true, want: pkexec{}},
+		{name: "doas last in the gui", installed: []string{"doas", "run0"}, graphical: true, want: run0{}},
+		{name: "doas in the terminal", installed: []string{"doas", "run0"}, want: doas{}},
+		{name: "nothing installed", want: nil},
+		{name: "chosen", installed: []string{"sudo", "run0"}, passwordless: []string{"sudo"}, elevator: "run0", want: run0{}},
+		{name: "chosen none", elevator: "none", want: none{}},
+		{name: "chosen but missing", installed: []string{"sudo"}, elevator: "doas", wantErr: true},
+		{name: "unknown", installed: []string{"sudo"}, elevator: "su", wantErr: true},
+	}
+
+	defer func() { geteuid = os.Geteuid }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := runner.NewFake()
+			fakeElevators(fake, tt.installed...)
+			geteuid = func() int {
+				if tt.root {
+					return 0
+				}
+				return 1000
+			}
+			for _, name := range []string{"sudo", "doas"} {
+				response := runner.Response{Stderr: name + ": a password is required\n", ExitCode: 1}
+				for _, passwordless := range tt.passwordless {
+					if passwordless == name {
+						response = runner.Response{}
+					}
+				}
+				fake.Add(name+" -n true", response)
+			}
+
+			got, err := detectElevator(context.Background(), fake, tt.elevator, tt.graphical)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("detectElevator() error = %v, want error %v", err, tt.wantErr)
+			}
+			if s, ok := got.(sudo); ok {
+				// The runner only matters for NeedsPassword.
+				s.r = nil
+				got = s
+			}
+			if got != tt.want {
+				t.Errorf("detectElevator() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSudoNeedsPassword(t *testing.T) {
+	fake := runner.NewFake()
+	fakeElevators(fake, "sudo")
+	fake.Add("sudo -n true", runner.Response{ExitCode: 1})
+	if !(sudo{fake}).NeedsPassword(context.Background()) {
+		t.Error("NeedsPassword() = false, want true while sudo asks for it")
+	}
+	fake.Add("sudo -n true", runner.Response{})
+	if (sudo{fake}).NeedsPassword(context.Background()) {
+		t.Error("NeedsPassword() = true, want false once sudo remembers it")
+	}
+}
@@ -0,0 +1,97 @@
+package platform
+
+import (
+	"image/color"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// InstallLog keeps the output of everything the GUI ran, so it can be
+// followed while the commands run and looked at after they finished or failed.
+type InstallLog struct {
+	// Lines holds the log line by line, so views only render the lines they
+	// show and appending stays cheap however long the log gets.
+	Lines binding.StringList
+}
+
+func NewInstallLog() *InstallLog {
+	return &InstallLog{Lines: binding.NewStringList()}
+}
+
+// Println appends line to the log, one entry per line it contains.
+func (l *InstallLog) Println(line string) {
+	for _, line := range strings.Split(line, "\n") {
+		l.Lines.Append(line)
+	}
+}
+
+// Empty reports whether nothing was logged yet.
+func (l *InstallLog) Empty() bool {
+	return l.Lines.Length() == 0
+}
+
+// lineWriter passes every line written to it to onLine. Carriage returns end
+// lines as well, as package managers use them to redraw progress. Writes to
+// stdout and stderr of a command may come concurrently.
+type lineWriter struct {
+	mu     sync.Mutex
+	line   []byte
+	onLine func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, b := range p {
+		if b != '\n' && b != '\r' {
+			w.line = append(w.line, b)
+			continue
+		}
+		if len(w.line) > 0 {
+			w.onLine(string(w.line))
+			w.line = w.line[:0]
+		}
+	}
+	return len(p), nil
+}
+
+// Flush passes on the last line if it did not end with a newline.
+func (w *lineWriter) Flush() {
+	w.Write([]byte{'\n'})
+}
+
+// newLogView shows the log in a scrollable list that follows new output. The
+// returned function stops following it.
+func newLogView(log *InstallLog) (fyne.CanvasObject, func()) {
+	list := widget.NewListWithData(log.Lines,
+		func() fyne.CanvasObject {
+			line := widget.NewLabel("")
+			line.TextStyle = fyne.TextStyle{Monospace: true}
+			return line
+		},
+		func(item binding.DataItem, object fyne.CanvasObject) {
+			object.(*widget.Label).Bind(item.(binding.String))
+		})
+	size := canvas.NewRectangle(color.Transparent)
+	size.SetMinSize(fyne.NewSize(640, 240))
+
+	follow := binding.NewDataListener(list.ScrollToBottom)
+	log.Lines.AddListener(follow)
+	return container.NewStack(size, list), func() { log.Lines.RemoveListener(follow) }
+}
+
+// ShowLog shows the output of everything the GUI ran so far.
+func (pm *PlatformManager) ShowLog(window fyne.Window) {
+	view, stop := newLogView(pm.Log)
+	logDialog := dialog.NewCustom("Protokoll", "Schließen", view, window)
+	logDialog.SetOnClosed(stop)
+	logDialog.Resize(fyne.NewSize(720, 480))
+	logDialog.Show()
+}
@@ -1,99 +1,175 @@
 package platform
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/PatrykHegenberg/jws_gui/internal/catalog"
 	"github.com/PatrykHegenberg/jws_gui/internal/system/operatingsystem"
 	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
 	"golang.org/x/term"
 )
 
-var requiredPackages = map[string][]*packagemanager.Package{
-	"git": {
-		{
-			Name:          "git",
-			SystemPackage: true,
-			NativePackageName: map[string]string{
-				"apt":      "git",
-				"dnf":      "git",
-				"pacman":   "git",
-				"zypper":   "git",
-				"homebrew": "git",
-				"choco":    "git",
-			},
-		},
-	},
-	"openjdk": {
-		{
-			Name:          "openjdk",
-			SystemPackage: true,
-			NativePackageName: map[string]string{
-				"apt":      "openjdk-17-jdk",
-				"dnf":      "java-17-openjdk-devel",
-				"pacman":   "jdk17-openjdk",
-				"zypper":   "java-17-openjdk-devel",
-				"homebrew": "openjdk@17",
-				"choco":    "openjdk",
-			},
-		},
-	},
-	"podman": {
-		{
-			Name:          "podman",
-			SystemPackage: true,
-			NativePackageName: map[string]string{
-				"apt":      "podman",
-				"dnf":      "podman",
-				"pacman":   "podman",
-				"zypper":   "podman",
-				"homebrew": "podman",
-				"choco":    "podman",
-			},
-		},
-	},
-	"vscode": {
-		{
-			Name:          "vscode",
-			SystemPackage: true,
-			NativePackageName: map[string]string{
-				"apt":      "code",
-				"dnf":      "code",
-				"pacman":   "code",
-				"zypper":   "code",
-				"homebrew": "visual-studio-code",
-				"choco":    "vscode",
-			},
-		},
-	},
-}
+const (
+	queryTimeout   = 30 * time.Second
+	installTimeout = 30 * time.Minute
+)
+
+// ErrCancelled is returned on the command line when the user cancelled the
+// plans with Ctrl+C.
+var ErrCancelled = errors.New("Vorgang abgebrochen")
 
 type SoftwareRequirement struct {
 	Name           string
 	Package        *packagemanager.Package
-	InstallCommand string
+	InstallCommand runner.Command
 	Installed      bool
 	InstalledBind  binding.Bool
+	StatusBind     binding.Int
+	Status         Status
+	// Constraint restricts the acceptable versions, nil accepts any.
+	Constraint *version.Constraint
+	// Manager is the backend that handles the package.
+	Manager packagemanager.PackageManager
+	// Repository is the third-party repository the package comes from with
+	// its backend, if any. Available is false as long as the repository has
+	// not been added.
+	Repository *packagemanager.Repository
+	Available  bool
+	// Version is the installed version and Candidate the newest version
+	// available from the package manager.
+	Version   string
+	Candidate string
+	// Location is where a probe found the tool if it was installed without
+	// the package manager.
+	Location string
+}
+
+// setStatus sets the status. Installed is only true if the requirement is
+// satisfied, so a version that is too old counts as missing.
+func (r *SoftwareRequirement) setStatus(status Status) {
+	r.Status = status
+	r.Installed = status.Satisfied()
+	if r.InstalledBind != nil {
+		r.InstalledBind.Set(r.Installed)
+	}
+	if r.StatusBind != nil {
+		r.StatusBind.Set(int(status))
+	}
+}
+
+// setPackageStatus takes over the versions and the state of a package queried
+// with manager.
+func (r *SoftwareRequirement) setPackageStatus(status packagemanager.PackageStatus, manager packagemanager.PackageManager) {
+	r.Version = ""
+	if status.Installed {
+		r.Version = status.Version
+	}
+	r.Candidate = status.Candidate
+	r.Available = status.Available
+
+	if deployer, ok := packagemanager.Resolve(manager, r.Package).(packagemanager.Deployer); ok && deployer.Pending(r.Package) {
+		r.setStatus(StatusRebootRequired)
+		return
+	}
+	r.setStatus(statusFromPackage(status, versionScheme(manager, r.Package), r.Constraint))
+}
+
+// setProbeStatus takes over the first tool found by the probes whose version
+// satisfies the constraint. A tool whose version is unknown is taken as it
+// is, with a log line that the constraint could not be checked.
+func (r *SoftwareRequirement) setProbeStatus(results []packagemanager.ProbeResult) {
+	for _, result := range results {
+		if r.Constraint != nil && result.Version != "" && !r.Constraint.Check(version.Generic, result.Version) {
+			log.Printf("%s in %s ist in Version %s zu alt, benötigt wird %s", r.Name, result.Path, result.Version, r.Constraint)
+			continue
+		}
+		if r.Constraint != nil && result.Version == "" {
+			log.Printf("Version von %s in %s unbekannt, %s wird nicht geprüft", r.Name, result.Path, r.Constraint)
+		}
+		r.Location = result.Path
+		r.Version = result.Version
+		r.setStatus(StatusExternal)
+		return
+	}
+}
+
+// candidateSatisfies reports whether installing or upgrading to the candidate
+// version would satisfy the constraint.
+func (r *SoftwareRequirement) candidateSatisfies(scheme version.Scheme) bool {
+	return r.Constraint == nil || r.Candidate == "" || r.Constraint.Check(scheme, r.Candidate)
+}
+
+// Options change how the platform manager installs requirements.
+type Options struct {
+	// Rootless installs into the directory of the user instead of using the
+	// native package manager, so no root privileges are needed.
+	Rootless bool
+	// ArchiveCache is the directory downloaded archives are kept in. It may
+	// be filled in advance, so nothing has to be downloaded.
+	ArchiveCache string
+	// Manager names the package manager to use instead of the one found
+	// first. The other package managers found remain fallbacks.
+	Manager string
+	// Catalog is a catalog that overrides the default one and the ones of
+	// the administrator and the user.
+	Catalog string
+	// SystemInstallation installs with backends like Flatpak for the whole
+	// system instead of only for the user.
+	SystemInstallation bool
+	// Elevator names the program that runs commands as root, one of
+	// Elevators. Empty or "auto" detects it.
+	Elevator string
+	// Graphical tells that the GUI runs, which prefers an elevator that asks
+	// for the password with a dialog.
+	Graphical bool
 }
 
 type PlatformManager struct {
 	PackageManager packagemanager.PackageManager
+	Runner         runner.Runner
 	Requirements   []*SoftwareRequirement
 	OS             *operatingsystem.OS
 	AllInstalled   binding.Bool
+	// Packages are the packages of the catalog with their native names
+	// resolved for the system, in catalog order.
+	Packages []*packagemanager.Package
+	// Managers are the other backends found, like Flatpak, ranked by how
+	// well they suit the system. Packages can prefer them, and they take over
+	// packages the native package manager does not have.
+	Managers []packagemanager.PackageManager
+	// UserPrefix installs release archives into the directory of the user.
+	// What it installed satisfies requirements in every mode.
+	UserPrefix *packagemanager.UserPrefix
+	Rootless   bool
+	// Elevator runs the commands that need root privileges. It is nil if
+	// none was found.
+	Elevator Elevator
+	// Log keeps the output of the plans the GUI ran.
+	Log *InstallLog
 }
 
-func NewPlatformManager() *PlatformManager {
+func NewPlatformManager(ctx context.Context, r runner.Runner, options Options) *PlatformManager {
 	pm := &PlatformManager{
+		Runner:       r,
 		AllInstalled: binding.NewBool(),
+		Log:          NewInstallLog(),
+		Rootless:     options.Rootless,
 	}
 
 	osInfo, err := operatingsystem.Info()
@@ -102,204 +178,831 @@ func NewPlatformManager() *PlatformManager {
 	}
 	pm.OS = osInfo
 
-	pm.PackageManager = packagemanager.Find(osInfo.ID)
-	if pm.PackageManager == nil {
+	pkgs, err := catalog.Load(catalog.Paths(options.Catalog)...)
+	if err == nil {
+		err = packagemanager.ResolveNames(pkgs, osInfo)
+	}
+	if err != nil {
+		log.Fatalf("Ungültiger Paketkatalog:\n%v", err)
+	}
+	pm.Packages = pkgs
+
+	prefix, err := packagemanager.DefaultPrefix()
+	if err != nil {
+		log.Fatalf("Konnte Benutzerverzeichnis nicht bestimmen: %v", err)
+	}
+	cache := options.ArchiveCache
+	if cache == "" {
+		if cache, err = packagemanager.DefaultCacheDir(); err != nil {
+			log.Fatalf("Konnte Cache-Verzeichnis nicht bestimmen: %v", err)
+		}
+	}
+	pm.UserPrefix = packagemanager.NewUserPrefix(prefix, cache, r)
+
+	if !options.Rootless {
+		if pm.Elevator, err = detectElevator(ctx, r, options.Elevator, options.Graphical); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	managers := packagemanager.Find(osInfo, r)
+	for _, manager := range managers {
+		if scoped, ok := manager.(packagemanager.Scoped); ok {
+			scoped.SetSystem(options.SystemInstallation)
+		}
+	}
+	if options.Manager != "" {
+		i := slices.IndexFunc(managers, func(m packagemanager.PackageManager) bool {
+			return m.Name() == options.Manager
+		})
+		if i < 0 {
+			log.Fatalf("Paketmanager %s nicht gefunden", options.Manager)
+		}
+		managers = append([]packagemanager.PackageManager{managers[i]}, slices.Delete(managers, i, i+1)...)
+	}
+
+	switch {
+	case len(managers) > 0:
+		combined := packagemanager.NewCombined(managers[0], pm.UserPrefix)
+		combined.PreferSecondary = options.Rootless
+		pm.PackageManager = combined
+	case options.Rootless:
+		pm.PackageManager = pm.UserPrefix
+	default:
 		log.Fatal("Kein unterstützter Paketmanager gefunden")
 	}
+	if len(managers) > 0 {
+		pm.Managers = managers[1:]
+	}
 
-	pm.initRequirements()
+	pm.initRequirements(ctx)
 
 	return pm
 }
 
-func (pm *PlatformManager) initRequirements() {
-	for name, packages := range requiredPackages {
-		for _, pkg := range packages {
-			requirement := &SoftwareRequirement{
-				Name:    name,
-				Package: pkg,
-			}
+func (pm *PlatformManager) initRequirements(ctx context.Context) {
+	var pkgs []*packagemanager.Package
+	for _, pkg := range pm.Packages {
+		pkgs = append(pkgs, pkg.Choices()...)
+	}
+
+	// A backend that cannot be queried is left out, the others and the
+	// probes are checked all the same.
+	statuses := map[packagemanager.PackageManager]map[*packagemanager.Package]packagemanager.PackageStatus{}
+	for _, manager := range pm.backends() {
+		result, err := pm.queryPackages(ctx, manager, pkgs)
+		if err != nil {
+			log.Printf("Fehler bei Installationsprüfung mit %s: %v", manager.Name(), err)
+			continue
+		}
+		statuses[manager] = result
+	}
+	_, queried := statuses[pm.PackageManager]
+
+	for _, pkg := range pm.Packages {
+		var constraint *version.Constraint
+		if pkg.Constraint != "" {
+			// The catalog is validated when it is loaded.
+			constraint, _ = version.ParseConstraint(pkg.Constraint)
+		}
+		choice, manager, ok := pm.selectPackage(pkg, constraint, statuses)
+		if !ok {
+			// No backend has the package, but the tool may have been
+			// installed without one.
+			manager = pm.PackageManager
+		}
+		status := statuses[manager][choice]
+		choice.Version = status.Version
+
+		requirement := &SoftwareRequirement{
+			Name:           pkg.Name,
+			Package:        choice,
+			Manager:        manager,
+			InstallCommand: manager.InstallCommand(choice),
+			InstalledBind:  binding.NewBool(),
+			StatusBind:     binding.NewInt(),
+			Repository:     repository(manager, choice),
+			Constraint:     constraint,
+		}
+		requirement.setPackageStatus(status, manager)
+		if !ok && !queried {
+			// The package manager may well have the package.
+			requirement.setStatus(StatusUnknown)
+		}
+		if !requirement.Installed {
+			requirement.setProbeStatus(pm.runProbes(ctx, pkg))
+		}
+		if !ok && queried && requirement.Status != StatusExternal {
+			log.Printf("Paket %s nicht verfügbar", pkg.Name)
+			continue
+		}
+
+		pm.Requirements = append(pm.Requirements, requirement)
+	}
+	pm.checkAllInstalled()
+}
+
+// backends returns the native package manager followed by the other backends.
+func (pm *PlatformManager) backends() []packagemanager.PackageManager {
+	return append([]packagemanager.PackageManager{pm.PackageManager}, pm.Managers...)
+}
 
-			available, err := pm.PackageManager.PackageAvailable(pkg)
-			if err != nil || !available {
-				log.Printf("Paket %s nicht verfügbar: %v", name, err)
-				continue
+// candidates returns the backends that may handle pkg, in order: the ones pkg
+// prefers, then the native package manager and then the other backends.
+func (pm *PlatformManager) candidates(pkg *packagemanager.Package) []packagemanager.PackageManager {
+	var candidates []packagemanager.PackageManager
+	for _, name := range pkg.Managers {
+		for _, manager := range pm.Managers {
+			if manager.Name() == name && !slices.Contains(candidates, manager) {
+				candidates = append(candidates, manager)
 			}
+		}
+	}
+	for _, manager := range pm.backends() {
+		if !slices.Contains(candidates, manager) {
+			candidates = append(candidates, manager)
+		}
+	}
+	return candidates
+}
 
-			installed, err := pm.PackageManager.PackageInstalled(pkg)
-			if err != nil {
-				log.Printf("Fehler bei Installationsprüfung für %s: %v", name, err)
-				continue
+// selectPackage returns the package among the choices of pkg that fulfills
+// the requirement, and its backend. A package installed in a version that
+// satisfies constraint wins, then one installed in any version, so an
+// existing installation is never shadowed. Otherwise the first choice a
+// candidate backend has available is used, then the first one a backend can
+// add a repository for. ok is false if no backend can install any choice.
+func (pm *PlatformManager) selectPackage(pkg *packagemanager.Package, constraint *version.Constraint, statuses map[packagemanager.PackageManager]map[*packagemanager.Package]packagemanager.PackageStatus) (choice *packagemanager.Package, manager packagemanager.PackageManager, ok bool) {
+	for _, check := range []func(packagemanager.PackageManager, *packagemanager.Package) bool{
+		func(m packagemanager.PackageManager, p *packagemanager.Package) bool {
+			status := statuses[m][p]
+			return status.Installed && (constraint == nil || constraint.Check(versionScheme(m, p), status.Version))
+		},
+		func(m packagemanager.PackageManager, p *packagemanager.Package) bool { return statuses[m][p].Installed },
+		func(m packagemanager.PackageManager, p *packagemanager.Package) bool { return statuses[m][p].Available },
+		func(m packagemanager.PackageManager, p *packagemanager.Package) bool { return repository(m, p) != nil },
+	} {
+		for _, choice := range pkg.Choices() {
+			candidates := slices.DeleteFunc(pm.candidates(choice), func(m packagemanager.PackageManager) bool {
+				_, queried := statuses[m]
+				return !queried
+			})
+			if i := slices.IndexFunc(candidates, func(m packagemanager.PackageManager) bool { return check(m, choice) }); i >= 0 {
+				return choice, candidates[i], true
 			}
+		}
+	}
+	return pkg, nil, false
+}
 
-			requirement.Installed = installed
-			requirement.InstallCommand = pm.PackageManager.InstallCommand(pkg)
-			requirement.InstalledBind = binding.NewBool()
-			requirement.InstalledBind.Set(requirement.Installed)
+// versionScheme returns the version rules of the backend of manager that
+// handles pkg, as a combined backend mixes sources with different rules.
+func versionScheme(manager packagemanager.PackageManager, pkg *packagemanager.Package) version.Scheme {
+	return packagemanager.Resolve(manager, pkg).VersionScheme()
+}
+
+// queryPackages queries manager for pkgs.
+func (pm *PlatformManager) queryPackages(ctx context.Context, manager packagemanager.PackageManager, pkgs []*packagemanager.Package) (map[*packagemanager.Package]packagemanager.PackageStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return manager.QueryPackages(ctx, pkgs)
+}
 
-			pm.Requirements = append(pm.Requirements, requirement)
+// runProbes looks for the tool of pkg outside the package managers.
+func (pm *PlatformManager) runProbes(ctx context.Context, pkg *packagemanager.Package) []packagemanager.ProbeResult {
+	if len(pkg.Probes) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return packagemanager.RunProbes(ctx, pm.Runner, pkg)
+}
+
+func (pm *PlatformManager) MissingRequirements() []*SoftwareRequirement {
+	var missing []*SoftwareRequirement
+	for _, req := range pm.Requirements {
+		if !req.Installed {
+			missing = append(missing, req)
 		}
 	}
+	return missing
 }
 
-func (pm *PlatformManager) CheckAndInstallRequirements(gui bool, window fyne.Window) error {
+func (pm *PlatformManager) OutdatedRequirements() []*SoftwareRequirement {
+	var outdated []*SoftwareRequirement
+	for _, req := range pm.Requirements {
+		if req.Status == StatusOutdated {
+			outdated = append(outdated, req)
+		}
+	}
+	return outdated
+}
+
+func (pm *PlatformManager) CheckAndInstallRequirements(ctx context.Context, gui bool, window fyne.Window) error {
+	missing := pm.MissingRequirements()
+
 	if !gui {
-		for _, req := range pm.Requirements {
-			if !req.Installed {
+		var selected []*SoftwareRequirement
+		for _, req := range missing {
+			if req.Status == StatusTooOld {
+				fmt.Printf("%s ist in Version %s installiert, benötigt wird %s. Möchten Sie es aktualisieren? (j/n): ",
+					req.Name, req.Version, req.Constraint)
+			} else if req.Package.Optional {
+				fmt.Printf("Möchten Sie %s installieren (optional)? (j/n): ", req.Name)
+			} else {
 				fmt.Printf("Möchten Sie %s installieren? (j/n): ", req.Name)
-				var response string
-				fmt.Scanln(&response)
+			}
+			var response string
+			fmt.Scanln(&response)
 
-				if strings.ToLower(response) == "j" {
-					err := pm.installPackage(req.Package, false, nil)
-					if err != nil {
-						return fmt.Errorf("Fehler bei Installation von %s: %v", req.Name, err)
-					}
-					req.Installed = true
-				}
+			if strings.ToLower(response) == "j" {
+				selected = append(selected, req)
+			}
+		}
+
+		selected, pulled := pm.withRequired(selected)
+		for _, req := range selected {
+			if by, ok := pulled[req]; ok {
+				fmt.Println(pulledMessage(req, by))
+			}
+		}
+		plans, unsatisfiable := pm.installPlans(selected)
+		for _, req := range unsatisfiable {
+			fmt.Println(unsatisfiableMessage(req))
+		}
+		if len(plans) == 0 {
+			return nil
+		}
+
+		for _, plan := range plans {
+			fmt.Printf("Schritte für %s:\n", plan.Action())
+			for _, step := range plan.Steps(pm.Elevator) {
+				fmt.Printf("  %s\n", step)
 			}
 		}
+		return pm.runPlans(ctx, plans...)
+	}
+
+	if len(missing) == 0 {
+		dialog.ShowInformation("Fertig", "Alle Pakete sind bereits installiert.", window)
 		return nil
 	}
 
-	var queue []*SoftwareRequirement
+	names := make([]string, len(missing))
+	for i, req := range missing {
+		names[i] = req.Name
+	}
+	selection := widget.NewCheckGroup(names, nil)
+	selection.SetSelected(names)
+	// Selecting a requirement also selects the ones it needs.
+	selection.OnChanged = func(selectedNames []string) {
+		var selected []*SoftwareRequirement
+		for _, req := range missing {
+			if slices.Contains(selectedNames, req.Name) {
+				selected = append(selected, req)
+			}
+		}
+		all, _ := pm.withRequired(selected)
+		if len(all) > len(selected) {
+			var names []string
+			for _, req := range all {
+				names = append(names, req.Name)
+			}
+			selection.SetSelected(names)
+		}
+	}
+
+	content := container.NewVBox(widget.NewLabel("Folgende Pakete werden installiert:"), selection)
+	for _, req := range missing {
+		if required := missingRequired(req, missing); len(required) > 0 {
+			content.Add(widget.NewLabel(fmt.Sprintf("%s benötigt %s und wird danach installiert.",
+				req.Name, strings.Join(required, ", "))))
+		}
+		if req.Status == StatusTooOld {
+			content.Add(widget.NewLabel(fmt.Sprintf("%s ist in Version %s installiert und wird aktualisiert, benötigt wird %s.",
+				req.Name, req.Version, req.Constraint)))
+		}
+		if req.Repository != nil && !req.Available {
+			content.Add(widget.NewLabel(fmt.Sprintf("Für %s wird die Paketquelle %s (%s) hinzugefügt.",
+				req.Name, req.Repository.Description, req.Repository.URL)))
+		}
+	}
+
+	dialog.ShowCustomConfirm("Installation erforderlich", "Installieren", "Abbrechen", content,
+		func(install bool) {
+			if !install {
+				return
+			}
+
+			var selected []*SoftwareRequirement
+			for _, req := range missing {
+				if slices.Contains(selection.Selected, req.Name) {
+					selected = append(selected, req)
+				}
+			}
+			plans, unsatisfiable := pm.installPlans(selected)
+			if len(unsatisfiable) > 0 {
+				messages := make([]string, len(unsatisfiable))
+				for i, req := range unsatisfiable {
+					messages[i] = unsatisfiableMessage(req)
+				}
+				dialog.ShowError(errors.New(strings.Join(messages, "\n")), window)
+			}
+			if len(plans) == 0 {
+				return
+			}
+
+			pm.showPlans(ctx, window, plans...)
+		}, window)
+
+	return nil
+}
+
+// installPlans builds install plans for the missing requirements and upgrade
+// plans for those installed in a version that is too old, together with the
+// requirements they need, in the order they have to run. Requirements whose
+// candidate version would not satisfy their constraint either are returned
+// as unsatisfiable instead.
+func (pm *PlatformManager) installPlans(selected []*SoftwareRequirement) (plans []*Plan, unsatisfiable []*SoftwareRequirement) {
+	selected, _ = pm.withRequired(selected)
+	var satisfiable []*SoftwareRequirement
+	for _, req := range selected {
+		if req.candidateSatisfies(versionScheme(req.Manager, req.Package)) {
+			satisfiable = append(satisfiable, req)
+		} else {
+			unsatisfiable = append(unsatisfiable, req)
+		}
+	}
+
+	for _, stage := range stages(satisfiable) {
+		var install, upgrade []*SoftwareRequirement
+		for _, req := range stage {
+			if req.Status == StatusTooOld {
+				upgrade = append(upgrade, req)
+			} else {
+				install = append(install, req)
+			}
+		}
+		plans = append(plans, pm.NewInstallPlans(install)...)
+		plans = append(plans, pm.NewUpgradePlans(upgrade)...)
+	}
+	return plans, unsatisfiable
+}
+
+func unsatisfiableMessage(req *SoftwareRequirement) string {
+	return fmt.Sprintf("%s: Die verfügbare Version %s erfüllt %s nicht", req.Name, req.Candidate, req.Constraint)
+}
+
+// RemoveRequirement removes the packages of all installed requirements with
+// the given name after the user confirmed the native packages affected.
+func (pm *PlatformManager) RemoveRequirement(ctx context.Context, name string, gui bool, window fyne.Window) error {
+	var selected []*SoftwareRequirement
 	for _, req := range pm.Requirements {
-		if !req.Installed {
-			queue = append(queue, req)
-		}
-	}
-
-	var processNext func()
-	processNext = func() {
-		if len(queue) == 0 {
-			dialog.ShowInformation("Fertig", "Alle Pakete wurden verarbeitet.", window)
-			return
-		}
-
-		req := queue[0]
-		queue = queue[1:]
-
-		dialog.ShowConfirm("Installation erforderlich",
-			fmt.Sprintf("Möchten Sie %s installieren?", req.Name),
-			func(install bool) {
-				if install {
-					passwordEntry := widget.NewPasswordEntry()
-					dialog.ShowForm("Sudo-Passwort erforderlich", "OK", "Abbrechen",
-						[]*widget.FormItem{widget.NewFormItem("Sudo Passwort", passwordEntry)},
-						func(submitted bool) {
-							if !submitted {
-								dialog.ShowError(fmt.Errorf("Installation abgebrochen"), window)
-								processNext()
-								return
-							}
-
-							sudoPass := passwordEntry.Text
-							if sudoPass == "" {
-								dialog.ShowError(fmt.Errorf("Kein Passwort eingegeben"), window)
-								processNext()
-								return
-							}
-
-							go func() {
-								installCommand := pm.PackageManager.InstallCommand(req.Package)
-								cmd := exec.Command("sudo", "-S", "sh", "-c", installCommand)
-								cmd.Stdin = strings.NewReader(sudoPass + "\n")
-								cmd.Stdout = os.Stdout
-								cmd.Stderr = os.Stderr
-
-								err := cmd.Run()
-								fyne.CurrentApp().SendNotification(&fyne.Notification{
-									Title: "Installation abgeschlossen",
-									Content: fmt.Sprintf("Paket %s wurde %s",
-										req.Name,
-										func() string {
-											if err == nil {
-												req.Installed = true
-												req.InstalledBind.Set(true)
-												pm.checkAllInstalled()
-												return "erfolgreich installiert"
-											}
-											return fmt.Sprintf("mit Fehlern installiert: %v", err)
-										}()),
-								})
-								processNext()
-							}()
-						}, window)
-				} else {
-					processNext()
+		if req.Name == name && req.Status.Installed() {
+			selected = append(selected, req)
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("%s ist nicht installiert", name)
+	}
+
+	plans := pm.NewRemovePlans(selected)
+	var packages, commands []string
+	var repositories []repositoryRemoval
+	for _, plan := range plans {
+		packages = append(packages, plan.Packages...)
+		commands = append(commands, plan.CommandLine(pm.Elevator))
+		for _, setup := range pm.removeRepositories(plan.Requirements) {
+			repositories = append(repositories, repositoryRemoval{plan, setup})
+		}
+	}
+
+	if !gui {
+		fmt.Printf("Folgende Pakete werden entfernt: %s\n", strings.Join(packages, ", "))
+		for _, command := range commands {
+			fmt.Printf("Befehl: %s\n", command)
+		}
+		fmt.Print("Fortfahren? (j/n): ")
+		var response string
+		fmt.Scanln(&response)
+
+		if strings.ToLower(response) != "j" {
+			return nil
+		}
+
+		for _, removal := range repositories {
+			fmt.Printf("Paketquelle %s ebenfalls entfernen? (j/n): ", removal.setup.Repository.Name)
+			fmt.Scanln(&response)
+
+			if strings.ToLower(response) == "j" {
+				removal.add()
+			}
+		}
+		return pm.runPlans(ctx, plans...)
+	}
+
+	command := widget.NewLabel(strings.Join(commands, "\n"))
+	command.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(
+		widget.NewLabel("Folgende Pakete werden entfernt:"),
+		widget.NewLabel(strings.Join(packages, ", ")),
+		command,
+	)
+
+	removeRepositories := make([]*widget.Check, len(repositories))
+	for i, removal := range repositories {
+		removeRepositories[i] = widget.NewCheck(fmt.Sprintf("Paketquelle %s ebenfalls entfernen", removal.setup.Repository.Name), nil)
+		content.Add(removeRepositories[i])
+	}
+
+	dialog.ShowCustomConfirm(fmt.Sprintf("%s entfernen", name), "Entfernen", "Abbrechen", content,
+		func(remove bool) {
+			if !remove {
+				return
+			}
+
+			for i, check := range removeRepositories {
+				if check.Checked {
+					repositories[i].add()
 				}
-			}, window)
+			}
+			pm.showPlans(ctx, window, plans...)
+		}, window)
+
+	return nil
+}
+
+// repositoryRemoval is a repository the user may remove together with the
+// packages of plan.
+type repositoryRemoval struct {
+	plan  *Plan
+	setup *packagemanager.RepositorySetup
+}
+
+func (r repositoryRemoval) add() {
+	r.plan.Repositories = append(r.plan.Repositories, r.setup)
+}
+
+// UpgradeRequirements upgrades all outdated requirements to their candidate
+// versions in one transaction per backend.
+func (pm *PlatformManager) UpgradeRequirements(ctx context.Context, gui bool, window fyne.Window) error {
+	outdated := pm.OutdatedRequirements()
+	if len(outdated) == 0 {
+		if gui {
+			dialog.ShowInformation("Fertig", "Alle Pakete sind aktuell.", window)
+		} else {
+			fmt.Println("Alle Pakete sind aktuell.")
+		}
+		return nil
+	}
+
+	plans := pm.NewUpgradePlans(outdated)
+	var commands []string
+	for _, plan := range plans {
+		commands = append(commands, plan.CommandLine(pm.Elevator))
+	}
+	var updates strings.Builder
+	for _, req := range outdated {
+		fmt.Fprintf(&updates, "%s: %s -> %s\n", req.Name, req.Version, req.Candidate)
+	}
+
+	if !gui {
+		fmt.Print(updates.String())
+		for _, command := range commands {
+			fmt.Printf("Befehl: %s\n", command)
+		}
+		fmt.Print("Fortfahren? (j/n): ")
+		var response string
+		fmt.Scanln(&response)
+
+		if strings.ToLower(response) != "j" {
+			return nil
+		}
+		return pm.runPlans(ctx, plans...)
 	}
 
-	processNext()
+	command := widget.NewLabel(strings.Join(commands, "\n"))
+	command.Wrapping = fyne.TextWrapWord
+	dialog.ShowCustomConfirm("Updates verfügbar", "Aktualisieren", "Abbrechen",
+		container.NewVBox(widget.NewLabel(updates.String()), command),
+		func(upgrade bool) {
+			if upgrade {
+				pm.showPlans(ctx, window, plans...)
+			}
+		}, window)
+
 	return nil
 }
 
-func (pm *PlatformManager) installPackage(pkg *packagemanager.Package, gui bool, window fyne.Window) error {
-	if gui {
-		passwordEntry := widget.NewPasswordEntry()
-		dialog.ShowForm("Sudo-Passwort erforderlich", "OK", "Abbrechen",
-			[]*widget.FormItem{widget.NewFormItem("Sudo Passwort", passwordEntry)},
-			func(submitted bool) {
-				if !submitted {
-					dialog.ShowError(fmt.Errorf("Installation abgebrochen"), window)
-					return
-				}
+// runPlans executes plans one after another on the command line, asking for
+// the password only once, and prints the status of every requirement
+// afterwards. It lists the plans that did not run and returns ErrCancelled
+// after a cancel, or the first error.
+func (pm *PlatformManager) runPlans(ctx context.Context, plans ...*Plan) error {
+	plans, refused := pm.rootlessPlans(plans)
+	for _, plan := range refused {
+		fmt.Println(rootlessMessage(plan))
+	}
+
+	var password string
+	if needsElevation(plans) {
+		if pm.Elevator == nil {
+			return errNoElevator
+		}
+		if pm.Elevator.NeedsPassword(ctx) {
+			var err error
+			password, err = readPassword()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var failed error
+	skipped := executePlans(ctx, plans, func(i int, plan *Plan) {
+		err := pm.executePlan(ctx, plan, password, terminal)
+		fmt.Print(plan.Summary())
+		if err != nil && ctx.Err() == nil && failed == nil {
+			failed = fmt.Errorf("Fehler bei %s von %s: %v", plan.Action(), plan.Names(), err)
+		}
+	})
+	for _, message := range skipped {
+		fmt.Println(message)
+	}
+	if ctx.Err() != nil {
+		return ErrCancelled
+	}
+	if failed != nil {
+		return failed
+	}
+	for _, hint := range pm.Hints() {
+		fmt.Println(hint)
+	}
+	return nil
+}
 
-				sudoPass := passwordEntry.Text
-				if sudoPass == "" {
-					dialog.ShowError(fmt.Errorf("Kein Passwort eingegeben"), window)
-					return
+// executePlans calls execute for plans one after another and returns a line
+// for every plan that did not run. A plan does not run if an earlier one did
+// not install what it requires, and after a cancel none of the remaining
+// plans run and their requirements count as cancelled.
+func executePlans(ctx context.Context, plans []*Plan, execute func(i int, plan *Plan)) (skipped []string) {
+	for i, plan := range plans {
+		if ctx.Err() != nil {
+			for _, rest := range plans[i:] {
+				for _, req := range rest.Requirements {
+					req.setStatus(StatusCancelled)
 				}
+				skipped = append(skipped, fmt.Sprintf("Nicht ausgeführt: %s von %s", rest.Action(), rest.Names()))
+			}
+			return skipped
+		}
+		if required := unmetRequired(plan, plans[:i]); len(required) > 0 {
+			skipped = append(skipped, fmt.Sprintf("Nicht ausgeführt: %s von %s, es fehlt %s",
+				plan.Action(), plan.Names(), strings.Join(required, ", ")))
+			continue
+		}
+		execute(i, plan)
+	}
+	return skipped
+}
 
-				installCommand := pm.PackageManager.InstallCommand(pkg)
-				cmd := exec.Command("sudo", "-S", "sh", "-c", installCommand)
-				cmd.Stdin = strings.NewReader(sudoPass + "\n")
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-
-				err := cmd.Run()
-				if err != nil {
-					dialog.ShowError(fmt.Errorf("Fehler bei Installation von %s: %v", pkg.Name, err), window)
-				} else {
-					dialog.ShowInformation("Installation erfolgreich",
-						fmt.Sprintf("%s wurde erfolgreich installiert", pkg.Name), window)
-					pm.checkAllInstalled()
+// showPlans executes plans in the background while a progress dialog is shown
+// and reports the status of every requirement and the plans that did not run
+// afterwards.
+func (pm *PlatformManager) showPlans(ctx context.Context, window fyne.Window, plans ...*Plan) {
+	plans, refused := pm.rootlessPlans(plans)
+	if len(refused) > 0 {
+		messages := make([]string, len(refused))
+		for i, plan := range refused {
+			messages[i] = rootlessMessage(plan)
+		}
+		dialog.ShowError(errors.New(strings.Join(messages, "\n")), window)
+	}
+	if len(plans) == 0 {
+		return
+	}
+
+	pm.askPassword(ctx, window, plans, func(password string) {
+		planCtx, cancel := context.WithCancel(ctx)
+		progress, bar := pm.showProgress(plans, cancel, window)
+
+		go func() {
+			var summary strings.Builder
+			skipped := executePlans(planCtx, plans, func(i int, plan *Plan) {
+				pm.executeLogged(planCtx, plan, password, func(fraction float64) {
+					// Package managers start over with every phase, the
+					// bar only moves forward.
+					if value := (float64(i) + fraction) / float64(len(plans)); value > bar.Value {
+						bar.SetValue(value)
+					}
+				})
+				bar.SetValue(float64(i+1) / float64(len(plans)))
+				summary.WriteString(plan.Summary())
+			})
+			for _, message := range skipped {
+				pm.Log.Println(message)
+				fmt.Fprintln(&summary, message)
+			}
+			title := fmt.Sprintf("%s abgeschlossen", plans[0].Action())
+			if planCtx.Err() != nil {
+				title = fmt.Sprintf("%s abgebrochen", plans[0].Action())
+			} else {
+				for _, hint := range pm.Hints() {
+					fmt.Fprintln(&summary, hint)
 				}
-			}, window)
-		return nil
+			}
+			progress.Hide()
+
+			fyne.CurrentApp().SendNotification(&fyne.Notification{
+				Title:   title,
+				Content: summary.String(),
+			})
+			result := dialog.NewCustom(title, "OK", container.NewVBox(
+				widget.NewLabel(summary.String()),
+				widget.NewButton("Protokoll anzeigen", func() { pm.ShowLog(window) }),
+			), window)
+			result.Show()
+		}()
+	})
+}
+
+// executeLogged executes plan with its output going to the log, and calls
+// onProgress with the progress of the transaction its output shows.
+func (pm *PlatformManager) executeLogged(ctx context.Context, plan *Plan, password string, onProgress func(float64)) {
+	pm.Log.Println(fmt.Sprintf("%s von %s", plan.Action(), plan.Names()))
+	for _, step := range plan.Steps(pm.Elevator) {
+		pm.Log.Println("$ " + step)
 	}
 
-	fmt.Print("Bitte geben Sie Ihr sudo-Passwort ein: ")
-	passBytes, err := term.ReadPassword(int(syscall.Stdin))
+	plan.ReportProgress = true
+	out := &lineWriter{onLine: func(line string) {
+		if fraction, ok := plan.Progress(line); ok {
+			onProgress(fraction)
+		}
+		pm.Log.Println(line)
+	}}
+	err := pm.executePlan(ctx, plan, password, output{out, out})
+	out.Flush()
+
 	if err != nil {
-		return fmt.Errorf("Fehler beim Lesen des Passworts: %v", err)
+		pm.Log.Println(fmt.Sprintf("Fehler: %v", err))
 	}
-	sudoPass := string(passBytes)
-	fmt.Println()
+	pm.Log.Println(strings.TrimSuffix(plan.Summary(), "\n"))
+}
+
+// rootlessPlans separates the plans that need root privileges in rootless mode.
+func (pm *PlatformManager) rootlessPlans(plans []*Plan) (allowed []*Plan, refused []*Plan) {
+	if !pm.Rootless {
+		return plans, nil
+	}
+	for _, plan := range plans {
+		if plan.NeedsElevation() {
+			refused = append(refused, plan)
+		} else {
+			allowed = append(allowed, plan)
+		}
+	}
+	return allowed, refused
+}
 
-	if sudoPass == "" {
-		return fmt.Errorf("Installation abgebrochen")
+func rootlessMessage(plan *Plan) string {
+	return fmt.Sprintf("%s von %s benötigt Administratorrechte und ist im Modus ohne root nicht möglich", plan.Action(), plan.Names())
+}
+
+// Hints returns notes on what the user still has to do after requirements
+// were installed.
+func (pm *PlatformManager) Hints() []string {
+	var hints []string
+	if hint := pm.pathHint(); hint != "" {
+		hints = append(hints, hint)
+	}
+	for _, req := range pm.Requirements {
+		if req.Status == StatusRebootRequired {
+			hints = append(hints, "Hinweis: Einige Änderungen werden erst nach einem Neustart wirksam.")
+			break
+		}
 	}
+	return hints
+}
 
-	installCommand := pm.PackageManager.InstallCommand(pkg)
-	cmd := exec.Command("sudo", "-S", "sh", "-c", installCommand)
-	cmd.Stdin = strings.NewReader(sudoPass + "\n")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// pathHint returns a note if requirements were installed into the directory of
+// the user whose executables are not on the PATH yet.
+func (pm *PlatformManager) pathHint() string {
+	bin := pm.UserPrefix.BinDir()
+	if slices.Contains(filepath.SplitList(os.Getenv("PATH")), bin) {
+		return ""
+	}
 
-	err = cmd.Run()
+	for _, req := range pm.Requirements {
+		if req.Status.Installed() && packagemanager.Resolve(req.Manager, req.Package) == pm.UserPrefix {
+			return fmt.Sprintf("Hinweis: Bitte %s zum PATH hinzufügen.", bin)
+		}
+	}
+	return ""
+}
+
+func needsElevation(plans []*Plan) bool {
+	for _, plan := range plans {
+		if plan.NeedsElevation() {
+			return true
+		}
+	}
+	return false
+}
+
+func planSteps(plans []*Plan, elevator Elevator) string {
+	var steps []string
+	for _, plan := range plans {
+		steps = append(steps, plan.Steps(elevator)...)
+	}
+	return strings.Join(steps, "\n")
+}
+
+// askPassword asks for the sudo password if one of the plans needs root
+// privileges and the elevator reads it from stdin, and calls onPassword
+// unless the dialog was cancelled or left empty.
+func (pm *PlatformManager) askPassword(ctx context.Context, window fyne.Window, plans []*Plan, onPassword func(password string)) {
+	if !needsElevation(plans) {
+		onPassword("")
+		return
+	}
+	if pm.Elevator == nil {
+		dialog.ShowError(errNoElevator, window)
+		return
+	}
+	if !pm.Elevator.NeedsPassword(ctx) {
+		onPassword("")
+		return
+	}
+
+	command := widget.NewLabel(planSteps(plans, pm.Elevator))
+	command.Wrapping = fyne.TextWrapWord
+	passwordEntry := widget.NewPasswordEntry()
+	dialog.ShowForm("Sudo-Passwort erforderlich", "OK", "Abbrechen",
+		[]*widget.FormItem{
+			widget.NewFormItem("Befehl", command),
+			widget.NewFormItem("Sudo Passwort", passwordEntry),
+		},
+		func(submitted bool) {
+			if !submitted {
+				dialog.ShowError(fmt.Errorf("%s abgebrochen", plans[0].Action()), window)
+				return
+			}
+
+			if passwordEntry.Text == "" {
+				dialog.ShowError(fmt.Errorf("Kein Passwort eingegeben"), window)
+				return
+			}
+
+			onPassword(passwordEntry.Text)
+		}, window)
+}
+
+func readPassword() (string, error) {
+	fmt.Print("Bitte geben Sie Ihr sudo-Passwort ein: ")
+	passBytes, err := term.ReadPassword(int(syscall.Stdin))
 	if err != nil {
-		return fmt.Errorf("Fehler bei Installation von %s: %v", pkg.Name, err)
+		return "", fmt.Errorf("Fehler beim Lesen des Passworts: %v", err)
 	}
+	fmt.Println()
 
-	return nil
+	if len(passBytes) == 0 {
+		return "", fmt.Errorf("Vorgang abgebrochen")
+	}
+	return string(passBytes), nil
 }
 
+// showProgress shows a dialog for running plans with a progress bar and the
+// log, whose button cancels them. Hiding the dialog once the plans are done is
+// harmless.
+func (pm *PlatformManager) showProgress(plans []*Plan, cancel context.CancelFunc, window fyne.Window) (dialog.Dialog, *widget.ProgressBar) {
+	content := container.NewVBox()
+	for _, plan := range plans {
+		content.Add(widget.NewLabel(fmt.Sprintf("%s wird %s...", plan.Names(), plan.Verb())))
+	}
+	bar := widget.NewProgressBar()
+	content.Add(bar)
+	view, stop := newLogView(pm.Log)
+
+	progress := dialog.NewCustom(fmt.Sprintf("%s läuft", plans[0].Action()), "Abbrechen",
+		container.NewBorder(content, nil, nil, nil, view), window)
+	progress.SetOnClosed(func() {
+		stop()
+		cancel()
+	})
+	progress.Resize(fyne.NewSize(720, 480))
+	progress.Show()
+	return progress, bar
+}
+
+// checkAllInstalled updates AllInstalled, which ignores optional
+// requirements.
 func (pm *PlatformManager) checkAllInstalled() {
 	allInstalled := true
 	for _, req := range pm.Requirements {
-		if !req.Installed {
+		if !req.Installed && !req.Package.Optional {
 			allInstalled = false
 			break
 		}
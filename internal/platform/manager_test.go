@@ -0,0 +1,177 @@
+//go:build linux
+
+package platform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fyne.io/fyne/v2/data/binding"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+// newTestManager returns a platform manager on Debian with apt as the package
+// manager and Flatpak as a further backend, all running their commands with
+// fake.
+func newTestManager(fake *runner.Fake) *PlatformManager {
+	return &PlatformManager{
+		PackageManager: packagemanager.NewApt("debian", fake),
+		Managers:       []packagemanager.PackageManager{packagemanager.NewFlatpak("debian", fake, false)},
+		Runner:         fake,
+		AllInstalled:   binding.NewBool(),
+		Elevator:       none{},
+		Log:            NewInstallLog(),
+	}
+}
+
+// testPackage returns a package known as native to the backend called
+// manager.
+func testPackage(name string, manager string, native string) *packagemanager.Package {
+	return &packagemanager.Package{
+		Name:              name,
+		SystemPackage:     true,
+		NativePackageName: map[string]string{manager: native},
+	}
+}
+
+// addRequirement adds a requirement for pkg with manager in status.
+func (pm *PlatformManager) addRequirement(pkg *packagemanager.Package, manager packagemanager.PackageManager, status Status) *SoftwareRequirement {
+	req := &SoftwareRequirement{
+		Name:          pkg.Name,
+		Package:       pkg,
+		Manager:       manager,
+		InstalledBind: binding.NewBool(),
+	}
+	req.setStatus(status)
+	pm.Requirements = append(pm.Requirements, req)
+	return req
+}
+
+func TestSelectPackage(t *testing.T) {
+	pm := newTestManager(runner.NewFake())
+	apt, flatpak := pm.PackageManager, pm.Managers[0]
+
+	type status = packagemanager.PackageStatus
+	available := status{Available: true, Version: "17.0.13"}
+	tests := []struct {
+		name string
+		// managers is the preference of the package.
+		managers []string
+		// apt and flatpak hold the statuses of the package and its
+		// alternative with each backend.
+		apt, flatpak [2]status
+		choice       int
+		manager      packagemanager.PackageManager
+		ok           bool
+	}{
+		{name: "preferred available", apt: [2]status{available, available}, choice: 0, manager: apt, ok: true},
+		{name: "preferred not available", apt: [2]status{{}, available}, choice: 1, manager: apt, ok: true},
+		{name: "installed alternative", apt: [2]status{available, {Installed: true, Version: "21.0.5"}}, choice: 1, manager: apt, ok: true},
+		{name: "satisfying alternative over too old package", apt: [2]status{{Installed: true, Version: "11.0.25"}, {Installed: true, Version: "21.0.5"}}, choice: 1, manager: apt, ok: true},
+		{name: "too old package over installing", apt: [2]status{{Installed: true, Version: "11.0.25"}, available}, choice: 0, manager: apt, ok: true},
+		{name: "native backend first", apt: [2]status{available}, flatpak: [2]status{available}, choice: 0, manager: apt, ok: true},
+		{name: "preferred backend first", managers: []string{"flatpak"}, apt: [2]status{available}, flatpak: [2]status{available}, choice: 0, manager: flatpak, ok: true},
+		{name: "installed with another backend", managers: []string{"flatpak"}, apt: [2]status{{Installed: true, Version: "17.0.13"}}, flatpak: [2]status{available}, choice: 0, manager: apt, ok: true},
+		{name: "only a further backend", flatpak: [2]status{{}, available}, choice: 1, manager: flatpak, ok: true},
+		{name: "nowhere", ok: false},
+	}
+
+	constraint, err := version.ParseConstraint(">= 17")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := testPackage("openjdk", "apt", "openjdk-17-jdk")
+			pkg.Managers = tt.managers
+			pkg.Alternatives = []*packagemanager.Package{testPackage("openjdk-21", "apt", "openjdk-21-jdk")}
+			choices := pkg.Choices()
+			statuses := map[packagemanager.PackageManager]map[*packagemanager.Package]status{
+				apt:     {choices[0]: tt.apt[0], choices[1]: tt.apt[1]},
+				flatpak: {choices[0]: tt.flatpak[0], choices[1]: tt.flatpak[1]},
+			}
+
+			choice, manager, ok := pm.selectPackage(pkg, constraint, statuses)
+			if ok != tt.ok {
+				t.Fatalf("selectPackage() ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if choice != choices[tt.choice] || manager != tt.manager {
+				t.Errorf("selectPackage() = %s with %s, want %s with %s", choice.Name, manager.Name(), choices[tt.choice].Name, tt.manager.Name())
+			}
+		})
+	}
+}
+
+func TestInitRequirementsPrimaryFails(t *testing.T) {
+	// The fake knows no commands, so neither apt nor Flatpak can be queried.
+	pm := newTestManager(runner.NewFake())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mvn"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	maven := testPackage("maven", "apt", "maven")
+	maven.Probes = []*packagemanager.Probe{{Binary: "mvn", Dirs: []string{dir}}}
+	pm.Packages = []*packagemanager.Package{testPackage("git", "apt", "git"), maven}
+
+	pm.initRequirements(context.Background())
+
+	want := map[string]Status{"git": StatusUnknown, "maven": StatusExternal}
+	if len(pm.Requirements) != len(want) {
+		t.Fatalf("got %d requirements, want %d", len(pm.Requirements), len(want))
+	}
+	for _, req := range pm.Requirements {
+		if req.Status != want[req.Name] || req.Manager != pm.PackageManager {
+			t.Errorf("%s: status %s with %s, want %s with %s", req.Name, req.Status, req.Manager.Name(), want[req.Name], pm.PackageManager.Name())
+		}
+	}
+}
+
+// routed is a backend that hands all packages on to backend, like a combined
+// one that resolved them to its secondary source.
+type routed struct {
+	packagemanager.PackageManager
+	backend packagemanager.PackageManager
+}
+
+func (r routed) Backend(...*packagemanager.Package) packagemanager.PackageManager {
+	return r.backend
+}
+
+func TestVersionSchemeOfResolvedBackend(t *testing.T) {
+	fake := runner.NewFake()
+	pm := newTestManager(fake)
+	// Debian orders 17~rc1 before 17, the generic rules of the user prefix
+	// after it.
+	manager := routed{pm.PackageManager, packagemanager.NewUserPrefix(t.TempDir(), t.TempDir(), fake)}
+	constraint, err := version.ParseConstraint(">= 17")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := pm.addRequirement(testPackage("openjdk", "apt", "openjdk-17-jdk"), manager, StatusMissing)
+	req.Constraint = constraint
+	req.setPackageStatus(packagemanager.PackageStatus{Installed: true, Available: true, Version: "17~rc1", Candidate: "17~rc1"}, manager)
+	if req.Status != StatusInstalled {
+		t.Errorf("status %s, want %s", req.Status, StatusInstalled)
+	}
+
+	// The package wins over an installed alternative as it satisfies the
+	// constraint as well.
+	pkg := req.Package
+	pkg.Alternatives = []*packagemanager.Package{testPackage("openjdk-21", "apt", "openjdk-21-jdk")}
+	choices := pkg.Choices()
+	statuses := map[packagemanager.PackageManager]map[*packagemanager.Package]packagemanager.PackageStatus{
+		manager: {choices[0]: {Installed: true, Version: "17~rc1"}, choices[1]: {Installed: true, Version: "21.0.5"}},
+	}
+	pm.Managers = []packagemanager.PackageManager{manager}
+	if choice, _, ok := pm.selectPackage(pkg, constraint, statuses); !ok || choice != choices[0] {
+		t.Errorf("selectPackage() = %s, want %s", choice.Name, choices[0].Name)
+	}
+}
@@ -0,0 +1,356 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+type Operation int
+
+const (
+	OperationInstall Operation = iota
+	OperationRemove
+	OperationUpgrade
+)
+
+// Plan installs, removes or upgrades a set of requirements in a single
+// transaction of one package manager, so the password is only needed once.
+type Plan struct {
+	Requirements []*SoftwareRequirement
+	// Manager is the backend that handles all requirements of the plan.
+	Manager packagemanager.PackageManager
+	Command runner.Command
+	// Installer does the work instead of Command if the backend installs
+	// the packages itself.
+	Installer packagemanager.Installer
+	// Packages are the native names of the packages the command works on.
+	Packages  []string
+	Operation Operation
+	// Repositories are set up before the command when installing and after
+	// it when removing.
+	Repositories []*packagemanager.RepositorySetup
+	// ReportProgress makes the command print its progress for Progress if
+	// the backend supports it.
+	ReportProgress bool
+}
+
+// output is where the commands of plans write to.
+type output struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// terminal is the output of the command line.
+var terminal = output{os.Stdout, os.Stderr}
+
+// NewInstallPlans returns one install plan per transaction of the backends the
+// requirements are handled by. Requirements are passed in the order of
+// Requirements, and the plans install them after the ones they require.
+func (pm *PlatformManager) NewInstallPlans(requirements []*SoftwareRequirement) []*Plan {
+	var plans []*Plan
+	for _, stage := range stages(requirements) {
+		for _, group := range transactions(stage) {
+			plan := pm.newPlan(group, OperationInstall)
+			plan.Repositories = pm.addRepositories(group)
+			plans = append(plans, plan)
+		}
+	}
+	return plans
+}
+
+func (pm *PlatformManager) NewRemovePlans(requirements []*SoftwareRequirement) []*Plan {
+	var plans []*Plan
+	for _, group := range transactions(requirements) {
+		plans = append(plans, pm.newPlan(group, OperationRemove))
+	}
+	return plans
+}
+
+func (pm *PlatformManager) NewUpgradePlans(requirements []*SoftwareRequirement) []*Plan {
+	var plans []*Plan
+	for _, group := range transactions(requirements) {
+		plans = append(plans, pm.newPlan(group, OperationUpgrade))
+	}
+	return plans
+}
+
+// newPlan builds the plan for requirements that share the same backend.
+func (pm *PlatformManager) newPlan(requirements []*SoftwareRequirement, operation Operation) *Plan {
+	manager := requirements[0].Manager
+	pkgs := requirementPackages(requirements)
+
+	plan := &Plan{
+		Requirements: requirements,
+		Manager:      manager,
+		Packages:     manager.NativeNames(pkgs...),
+		Operation:    operation,
+	}
+	if installer, ok := packagemanager.Resolve(manager, pkgs...).(packagemanager.Installer); ok {
+		plan.Installer = installer
+		return plan
+	}
+
+	switch operation {
+	case OperationRemove:
+		plan.Command = manager.RemoveCommand(pkgs...)
+	case OperationUpgrade:
+		plan.Command = manager.UpgradeCommand(pkgs...)
+	default:
+		plan.Command = manager.InstallCommand(pkgs...)
+	}
+	return plan
+}
+
+// transactions splits requirements into sets that one command of their
+// backend can handle.
+func transactions(requirements []*SoftwareRequirement) [][]*SoftwareRequirement {
+	var result [][]*SoftwareRequirement
+	for _, group := range groupByManager(requirements) {
+		batcher, ok := group[0].Manager.(packagemanager.Batcher)
+		if !ok {
+			result = append(result, group)
+			continue
+		}
+
+		byPackage := map[*packagemanager.Package][]*SoftwareRequirement{}
+		for _, req := range group {
+			byPackage[req.Package] = append(byPackage[req.Package], req)
+		}
+		for _, pkgs := range batcher.Batches(requirementPackages(group)) {
+			var batch []*SoftwareRequirement
+			for _, pkg := range pkgs {
+				batch = append(batch, byPackage[pkg]...)
+				delete(byPackage, pkg)
+			}
+			if len(batch) > 0 {
+				result = append(result, batch)
+			}
+		}
+	}
+	return result
+}
+
+// groupByManager splits requirements by the backend that handles them, in the
+// order the backends first appear.
+func groupByManager(requirements []*SoftwareRequirement) [][]*SoftwareRequirement {
+	var groups [][]*SoftwareRequirement
+	index := map[packagemanager.PackageManager]int{}
+	for _, req := range requirements {
+		i, ok := index[req.Manager]
+		if !ok {
+			i = len(groups)
+			index[req.Manager] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], req)
+	}
+	return groups
+}
+
+// Action names what the plan does, for messages to the user.
+func (p *Plan) Action() string {
+	switch p.Operation {
+	case OperationRemove:
+		return "Deinstallation"
+	case OperationUpgrade:
+		return "Aktualisierung"
+	}
+	return "Installation"
+}
+
+// Verb describes in passive voice what happens to the requirements.
+func (p *Plan) Verb() string {
+	switch p.Operation {
+	case OperationRemove:
+		return "entfernt"
+	case OperationUpgrade:
+		return "aktualisiert"
+	}
+	return "installiert"
+}
+
+func (p *Plan) Names() string {
+	names := make([]string, len(p.Requirements))
+	for i, req := range p.Requirements {
+		names[i] = req.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Summary lists the status of every requirement of the plan.
+func (p *Plan) Summary() string {
+	var summary strings.Builder
+	for _, req := range p.Requirements {
+		fmt.Fprintf(&summary, "%s: %s\n", req.Name, req.Status)
+	}
+	return summary.String()
+}
+
+// NeedsElevation reports whether the plan needs root privileges.
+func (p *Plan) NeedsElevation() bool {
+	if p.Command.Elevate {
+		return true
+	}
+	for _, setup := range p.Repositories {
+		if setup.NeedsElevation() {
+			return true
+		}
+	}
+	return false
+}
+
+// Steps describes everything the plan will do, in order, including the setup
+// of repositories. Commands that need root privileges are shown as elevator
+// runs them.
+func (p *Plan) Steps(elevator Elevator) []string {
+	var repositories []string
+	for _, setup := range p.Repositories {
+		action := "hinzufügen"
+		if p.Operation == OperationRemove {
+			action = "entfernen"
+		}
+		for _, step := range setup.Steps() {
+			repositories = append(repositories, fmt.Sprintf("Paketquelle %s %s: %s", setup.Repository.Name, action, step))
+		}
+	}
+
+	if p.Operation == OperationRemove {
+		return append(p.commandSteps(elevator), repositories...)
+	}
+	return append(repositories, p.commandSteps(elevator)...)
+}
+
+// commandSteps describes the transaction of the plan.
+func (p *Plan) commandSteps(elevator Elevator) []string {
+	if p.Installer == nil {
+		return []string{p.CommandLine(elevator)}
+	}
+
+	pkgs := requirementPackages(p.Requirements)
+	if p.Operation == OperationRemove {
+		return p.Installer.RemoveSteps(pkgs)
+	}
+	return p.Installer.InstallSteps(pkgs)
+}
+
+// CommandLine renders the command of the plan for the user, including the
+// elevation through elevator.
+func (p *Plan) CommandLine(elevator Elevator) string {
+	if p.Installer != nil {
+		return strings.Join(p.commandSteps(elevator), "\n")
+	}
+	if p.Command.Elevate && elevator != nil {
+		return elevator.Wrap(p.Command).Shell()
+	}
+	return p.Command.Shell()
+}
+
+// Progress returns the progress of the transaction from 0 to 1 that a line of
+// its output shows, if any.
+func (p *Plan) Progress(line string) (float64, bool) {
+	reporter, ok := p.progressReporter()
+	if !ok {
+		return 0, false
+	}
+	return reporter.Progress(line)
+}
+
+func (p *Plan) progressReporter() (packagemanager.ProgressReporter, bool) {
+	if p.Installer != nil {
+		return nil, false
+	}
+	reporter, ok := packagemanager.Resolve(p.Manager, requirementPackages(p.Requirements)...).(packagemanager.ProgressReporter)
+	return reporter, ok
+}
+
+// executePlan runs the transaction of the plan and afterwards checks every
+// requirement again, so each one gets its own status even if the transaction
+// as a whole failed.
+func (pm *PlatformManager) executePlan(ctx context.Context, plan *Plan, password string, out output) error {
+	runCtx, cancel := context.WithTimeout(ctx, installTimeout)
+	defer cancel()
+
+	var err error
+	if plan.Operation != OperationRemove {
+		err = pm.setupRepositories(runCtx, plan.Repositories, password, out)
+	}
+	if err == nil {
+		err = pm.runTransaction(runCtx, plan, password, out)
+	}
+	if err == nil && plan.Operation == OperationRemove {
+		err = pm.setupRepositories(runCtx, plan.Repositories, password, out)
+	}
+
+	pm.updateStatus(context.WithoutCancel(ctx), plan, err)
+	return err
+}
+
+// runTransaction runs the command of plan or lets its installer do the work.
+func (pm *PlatformManager) runTransaction(ctx context.Context, plan *Plan, password string, out output) error {
+	if plan.Installer == nil {
+		cmd := plan.Command
+		if reporter, ok := plan.progressReporter(); ok && plan.ReportProgress {
+			cmd = reporter.ReportProgress(cmd)
+		}
+		return pm.runElevated(ctx, cmd, password, out)
+	}
+
+	pkgs := requirementPackages(plan.Requirements)
+	if plan.Operation == OperationRemove {
+		return plan.Installer.Remove(ctx, pkgs)
+	}
+	return plan.Installer.Install(ctx, pkgs)
+}
+
+// runElevated runs cmd, through the elevator if it needs root privileges.
+// password is passed on to elevators that read it from stdin.
+func (pm *PlatformManager) runElevated(ctx context.Context, cmd runner.Command, password string, out output) error {
+	var stdin io.Reader
+	if cmd.Elevate {
+		if pm.Elevator == nil {
+			return errNoElevator
+		}
+		cmd = pm.Elevator.Wrap(cmd)
+		if password != "" {
+			stdin = strings.NewReader(password + "\n")
+		}
+	}
+	return pm.Runner.Run(ctx, cmd, stdin, out.stdout, out.stderr)
+}
+
+// updateStatus sets the status of the requirements of plan after its command
+// ended with runErr. Whatever is installed now counts as installed, even if
+// the transaction reported an error.
+func (pm *PlatformManager) updateStatus(ctx context.Context, plan *Plan, runErr error) {
+	statuses, err := pm.queryPackages(ctx, plan.Manager, requirementPackages(plan.Requirements))
+
+	for _, req := range plan.Requirements {
+		switch {
+		case err == nil && statuses[req.Package].Installed:
+			req.setPackageStatus(statuses[req.Package], plan.Manager)
+		case plan.Operation == OperationRemove && (err == nil || runErr == nil):
+			req.setPackageStatus(packagemanager.PackageStatus{}, plan.Manager)
+		case plan.Operation != OperationInstall:
+			// The command failed and the current state is unknown.
+		case err == nil && runErr == nil:
+			req.setStatus(StatusFailed)
+		default:
+			req.setStatus(statusFromError(runErr))
+		}
+	}
+	pm.checkAllInstalled()
+}
+
+func requirementPackages(requirements []*SoftwareRequirement) []*packagemanager.Package {
+	pkgs := make([]*packagemanager.Package, len(requirements))
+	for i, req := range requirements {
+		pkgs[i] = req.Package
+	}
+	return pkgs
+}
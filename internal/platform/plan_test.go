@@ -0,0 +1,110 @@
+//go:build linux
+
+package platform
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+func TestPlansRunOneCommandPerBackend(t *testing.T) {
+	tests := []struct {
+		name  string
+		plans func(pm *PlatformManager) []*Plan
+		want  []string
+	}{
+		{
+			name:  "install",
+			plans: func(pm *PlatformManager) []*Plan { return pm.NewInstallPlans(pm.Requirements) },
+			want: []string{
+				"apt install -y -- git openjdk-17-jdk",
+				"flatpak install --user --noninteractive -y flathub com.visualstudio.code",
+			},
+		},
+		{
+			name:  "remove",
+			plans: func(pm *PlatformManager) []*Plan { return pm.NewRemovePlans(pm.Requirements) },
+			want: []string{
+				"apt remove -y -- git openjdk-17-jdk",
+				"flatpak uninstall --user --noninteractive -y com.visualstudio.code",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := runner.NewFake()
+			for _, cmdline := range tt.want {
+				fake.Add(cmdline, runner.Response{})
+			}
+			pm := newTestManager(fake)
+			pm.addRequirement(testPackage("git", "apt", "git"), pm.PackageManager, StatusMissing)
+			pm.addRequirement(testPackage("vscode", "flatpak", "com.visualstudio.code"), pm.Managers[0], StatusMissing)
+			pm.addRequirement(testPackage("openjdk", "apt", "openjdk-17-jdk"), pm.PackageManager, StatusMissing)
+
+			plans := tt.plans(pm)
+			if len(plans) != len(tt.want) {
+				t.Fatalf("got %d plans %v, want %d", len(plans), planOrder(plans), len(tt.want))
+			}
+			for _, plan := range plans {
+				pm.executePlan(context.Background(), plan, "", output{io.Discard, io.Discard})
+			}
+
+			// The status queries after each plan fail with the fake, only
+			// the transactions count.
+			var got []string
+			for _, call := range fake.Calls {
+				if slices.Contains(tt.want, call.String()) {
+					got = append(got, call.String())
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("commands = %q, want each once: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPlansCancelled(t *testing.T) {
+	fake := runner.NewFake()
+	pm := newTestManager(fake)
+	pm.addRequirement(testPackage("git", "apt", "git"), pm.PackageManager, StatusMissing)
+	pm.addRequirement(testPackage("vscode", "flatpak", "com.visualstudio.code"), pm.Managers[0], StatusMissing)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pm.runPlans(ctx, pm.NewInstallPlans(pm.Requirements)...); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("runPlans() error = %v, want ErrCancelled", err)
+	}
+	if len(fake.Calls) > 0 {
+		t.Errorf("ran %v after the cancel", fake.Calls)
+	}
+	for _, req := range pm.Requirements {
+		if req.Status != StatusCancelled {
+			t.Errorf("%s: status %s, want %s", req.Name, req.Status, StatusCancelled)
+		}
+	}
+}
+
+func TestPlanStepsShowElevation(t *testing.T) {
+	pm := newTestManager(runner.NewFake())
+	pm.addRequirement(testPackage("git", "apt", "git"), pm.PackageManager, StatusMissing)
+	plan := pm.NewInstallPlans(pm.Requirements)[0]
+
+	for _, tt := range []struct {
+		elevator Elevator
+		want     string
+	}{
+		{sudo{}, "sudo -S -- apt install -y -- git"},
+		{none{}, "apt install -y -- git"},
+	} {
+		if got := plan.Steps(tt.elevator); !slices.Equal(got, []string{tt.want}) {
+			t.Errorf("Steps(%s) = %q, want %q", tt.elevator.Name(), got, tt.want)
+		}
+	}
+}
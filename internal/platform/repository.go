@@ -0,0 +1,128 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+// maxRepositoryFileSize limits downloads of signing keys and similar files.
+const maxRepositoryFileSize = 1 << 20
+
+// repository returns the third-party repository pkg needs with manager, or nil
+// if there is none or the backend cannot add it.
+func repository(manager packagemanager.PackageManager, pkg *packagemanager.Package) *packagemanager.Repository {
+	if _, ok := manager.(packagemanager.RepositoryManager); !ok {
+		return nil
+	}
+	return pkg.Repositories[manager.Name()]
+}
+
+// addRepositories returns the setups for the repositories the requirements
+// are not available without.
+func (pm *PlatformManager) addRepositories(requirements []*SoftwareRequirement) []*packagemanager.RepositorySetup {
+	var setups []*packagemanager.RepositorySetup
+	var seen []*packagemanager.Repository
+	for _, req := range requirements {
+		rm, ok := req.Manager.(packagemanager.RepositoryManager)
+		if !ok || req.Repository == nil || req.Available || slices.Contains(seen, req.Repository) {
+			continue
+		}
+		seen = append(seen, req.Repository)
+		setups = append(setups, rm.AddRepository(req.Repository))
+	}
+	return setups
+}
+
+// removeRepositories returns the setups that remove the repositories which
+// were added for the requirements.
+func (pm *PlatformManager) removeRepositories(requirements []*SoftwareRequirement) []*packagemanager.RepositorySetup {
+	var setups []*packagemanager.RepositorySetup
+	var seen []*packagemanager.Repository
+	for _, req := range requirements {
+		rm, ok := req.Manager.(packagemanager.RepositoryManager)
+		repo := req.Repository
+		if !ok || repo == nil || slices.Contains(seen, repo) || !rm.RepositoryConfigured(repo) {
+			continue
+		}
+		seen = append(seen, repo)
+		setups = append(setups, rm.RemoveRepository(repo))
+	}
+	return setups
+}
+
+// setupRepositories runs all steps of setups. Files are staged in a temporary
+// directory first and then installed with root privileges.
+func (pm *PlatformManager) setupRepositories(ctx context.Context, setups []*packagemanager.RepositorySetup, password string, out output) error {
+	if len(setups) == 0 {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "jws-repository-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, setup := range setups {
+		for i, file := range setup.Files {
+			staged := filepath.Join(dir, fmt.Sprintf("%s-%d", setup.Repository.Name, i))
+			if err := stageFile(ctx, file, staged); err != nil {
+				return fmt.Errorf("Paketquelle %s: %v", setup.Repository.Name, err)
+			}
+
+			err := pm.runElevated(ctx, runner.Command{
+				Name:    "install",
+				Args:    []string{"-D", "-m", "0644", "--", staged, file.Path},
+				Elevate: true,
+			}, password, out)
+			if err != nil {
+				return fmt.Errorf("Paketquelle %s: %s: %v", setup.Repository.Name, file.Path, err)
+			}
+		}
+
+		for _, cmd := range setup.Commands {
+			if err := pm.runElevated(ctx, cmd, password, out); err != nil {
+				return fmt.Errorf("Paketquelle %s: %s: %v", setup.Repository.Name, cmd.Shell(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// stageFile writes the content of file to path, downloading it if necessary.
+func stageFile(ctx context.Context, file packagemanager.RepositoryFile, path string) error {
+	if file.URL == "" {
+		return os.WriteFile(path, []byte(file.Content), 0o644)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Download von %s fehlgeschlagen: %s", file.URL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRepositoryFileSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxRepositoryFileSize {
+		return fmt.Errorf("Download von %s fehlgeschlagen: Datei zu groß", file.URL)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,125 @@
+package platform
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+)
+
+// withRequired adds the missing requirements that selected ones require,
+// directly or indirectly, and returns all of them in the order of
+// Requirements, which is the order they can be installed in. pulled maps
+// every requirement that was added to the names of the ones that need it.
+func (pm *PlatformManager) withRequired(selected []*SoftwareRequirement) (all []*SoftwareRequirement, pulled map[*SoftwareRequirement][]string) {
+	included := map[*SoftwareRequirement]bool{}
+	for _, req := range selected {
+		included[req] = true
+	}
+
+	// Requirements come after the ones they require, so going backwards
+	// reaches a pulled in requirement only after everything that needs it.
+	pulled = map[*SoftwareRequirement][]string{}
+	for i := len(pm.Requirements) - 1; i >= 0; i-- {
+		req := pm.Requirements[i]
+		if !included[req] {
+			continue
+		}
+		for _, name := range req.Package.Requires {
+			j := slices.IndexFunc(pm.Requirements, func(r *SoftwareRequirement) bool { return r.Name == name })
+			if j < 0 || pm.Requirements[j].Installed || slices.Contains(selected, pm.Requirements[j]) {
+				continue
+			}
+			required := pm.Requirements[j]
+			included[required] = true
+			pulled[required] = append(pulled[required], req.Name)
+		}
+	}
+
+	for _, req := range pm.Requirements {
+		if included[req] {
+			all = append(all, req)
+		}
+	}
+	return all, pulled
+}
+
+// missingRequired returns the names of the requirements among requirements
+// that req requires and that are missing.
+func missingRequired(req *SoftwareRequirement, requirements []*SoftwareRequirement) []string {
+	var names []string
+	for _, r := range requirements {
+		if slices.Contains(req.Package.Requires, r.Name) && !r.Installed {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// unmetRequired returns the names of the requirements that the requirements
+// of plan require, that one of the earlier plans was to install and that are
+// still missing. Removals do not depend on each other.
+func unmetRequired(plan *Plan, earlier []*Plan) []string {
+	if plan.Operation == OperationRemove {
+		return nil
+	}
+	var requirements []*SoftwareRequirement
+	for _, p := range earlier {
+		requirements = append(requirements, p.Requirements...)
+	}
+
+	var names []string
+	for _, req := range plan.Requirements {
+		for _, name := range missingRequired(req, requirements) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func pulledMessage(req *SoftwareRequirement, by []string) string {
+	slices.Sort(by)
+	return fmt.Sprintf("%s wird ebenfalls installiert, benötigt von %s", req.Name, strings.Join(by, ", "))
+}
+
+// stages splits requirements, in the order of Requirements, into stages that
+// run one after the other. A requirement shares the stage of the ones it
+// requires if the same transaction handles them, as the package manager
+// orders a transaction itself, and comes in a later stage otherwise.
+func stages(requirements []*SoftwareRequirement) [][]*SoftwareRequirement {
+	var result [][]*SoftwareRequirement
+	stage := map[string]int{}
+	byName := map[string]*SoftwareRequirement{}
+	for _, req := range requirements {
+		n := 0
+		for _, name := range req.Package.Requires {
+			required, ok := byName[name]
+			if !ok {
+				continue
+			}
+			if sameTransaction(req, required) {
+				n = max(n, stage[name])
+			} else {
+				n = max(n, stage[name]+1)
+			}
+		}
+
+		stage[req.Name] = n
+		byName[req.Name] = req
+		for len(result) <= n {
+			result = append(result, nil)
+		}
+		result[n] = append(result[n], req)
+	}
+	return result
+}
+
+// sameTransaction reports whether a and b are installed by the same command:
+// both by the same backend, and both installed or both upgraded.
+func sameTransaction(a *SoftwareRequirement, b *SoftwareRequirement) bool {
+	return packagemanager.Resolve(a.Manager, a.Package) == packagemanager.Resolve(b.Manager, b.Package) &&
+		(a.Status == StatusTooOld) == (b.Status == StatusTooOld)
+}
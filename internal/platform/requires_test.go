@@ -0,0 +1,104 @@
+//go:build linux
+
+package platform
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+// planOrder renders plans as "operation manager: names" in their order.
+func planOrder(plans []*Plan) []string {
+	var order []string
+	for _, plan := range plans {
+		order = append(order, plan.Action()+" "+plan.Manager.Name()+": "+plan.Names())
+	}
+	return order
+}
+
+func TestInstallPlansOrder(t *testing.T) {
+	fake := runner.NewFake()
+	pm := newTestManager(fake)
+	user := packagemanager.NewUserPrefix(t.TempDir(), t.TempDir(), fake)
+
+	jdk := pm.addRequirement(testPackage("openjdk", "apt", "openjdk-17-jdk"), pm.PackageManager, StatusMissing)
+	git := pm.addRequirement(testPackage("git", "apt", "git"), pm.PackageManager, StatusMissing)
+	maven := &packagemanager.Package{
+		Name:          "maven",
+		SystemPackage: true,
+		Requires:      []string{"openjdk"},
+		Archives:      map[string]*packagemanager.Archive{"any": {Version: "3.9.9", URL: "https://example.org/maven.tar.gz"}},
+	}
+	pm.addRequirement(maven, user, StatusMissing)
+	gradle := testPackage("gradle", "apt", "gradle")
+	gradle.Requires = []string{"openjdk"}
+	pm.addRequirement(gradle, pm.PackageManager, StatusMissing)
+
+	// The JDK is pulled in. Gradle is installed by apt in the same
+	// transaction, which orders the packages itself, Maven is unpacked
+	// afterwards.
+	selected, pulled := pm.withRequired([]*SoftwareRequirement{pm.Requirements[2], pm.Requirements[3]})
+	if !slices.Contains(selected, jdk) || slices.Contains(selected, git) {
+		t.Errorf("withRequired() = %v, want the JDK pulled in", selected)
+	}
+	if by := pulled[jdk]; strings.Join(by, " ") != "gradle maven" {
+		t.Errorf("openjdk pulled in by %v, want gradle and maven", by)
+	}
+
+	plans, unsatisfiable := pm.installPlans([]*SoftwareRequirement{pm.Requirements[2], pm.Requirements[3]})
+	want := []string{"Installation apt: openjdk, gradle", "Installation user: maven"}
+	if got := planOrder(plans); !slices.Equal(got, want) || len(unsatisfiable) > 0 {
+		t.Errorf("installPlans() = %q, %v, want %q", got, unsatisfiable, want)
+	}
+
+	// A JDK that is too old is upgraded by another command than the one that
+	// installs Gradle, which has to wait for it.
+	jdk.setStatus(StatusTooOld)
+	plans, _ = pm.installPlans([]*SoftwareRequirement{jdk, pm.Requirements[3]})
+	want = []string{"Aktualisierung apt: openjdk", "Installation apt: gradle"}
+	if got := planOrder(plans); !slices.Equal(got, want) {
+		t.Errorf("installPlans() with an old JDK = %q, want %q", got, want)
+	}
+}
+
+// A plan does not run after the plan that was to install what it requires
+// failed, the plans that do not depend on it still run.
+func TestRunPlansSkipsUnmetRequired(t *testing.T) {
+	fake := runner.NewFake()
+	fake.Add("apt install -y -- openjdk-17-jdk", runner.Response{ExitCode: 100})
+	fake.Add("flatpak install --user --noninteractive -y flathub org.gnome.Boxes", runner.Response{})
+	pm := newTestManager(fake)
+
+	jdk := pm.addRequirement(testPackage("openjdk", "apt", "openjdk-17-jdk"), pm.PackageManager, StatusMissing)
+	eclipse := testPackage("eclipse", "flatpak", "org.eclipse.Java")
+	eclipse.Requires = []string{"openjdk"}
+	pm.addRequirement(eclipse, pm.Managers[0], StatusMissing)
+	pm.addRequirement(testPackage("boxes", "flatpak", "org.gnome.Boxes"), pm.Managers[0], StatusMissing)
+
+	plans, _ := pm.installPlans(pm.Requirements)
+	want := []string{"Installation apt: openjdk", "Installation flatpak: boxes", "Installation flatpak: eclipse"}
+	if got := planOrder(plans); !slices.Equal(got, want) {
+		t.Fatalf("installPlans() = %q, want %q", got, want)
+	}
+	if err := pm.runPlans(context.Background(), plans...); err == nil {
+		t.Errorf("runPlans() succeeded with a failed plan")
+	}
+
+	var ran []string
+	for _, call := range fake.Calls {
+		if slices.Contains(call.Args, "install") {
+			ran = append(ran, call.String())
+		}
+	}
+	if want := []string{"apt install -y -- openjdk-17-jdk", "flatpak install --user --noninteractive -y flathub org.gnome.Boxes"}; !slices.Equal(ran, want) {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+	if jdk.Installed || pm.Requirements[1].Status != StatusMissing {
+		t.Errorf("openjdk %s, eclipse %s, want eclipse left missing", jdk.Status, pm.Requirements[1].Status)
+	}
+}
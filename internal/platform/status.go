@@ -0,0 +1,93 @@
+package platform
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/packagemanager"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+type Status int
+
+const (
+	StatusMissing Status = iota
+	StatusInstalled
+	StatusFailed
+	StatusCancelled
+	StatusOutdated
+	StatusTooOld
+	// StatusRebootRequired marks a requirement that is installed or removed
+	// in a deployment that only becomes active with the next reboot.
+	StatusRebootRequired
+	// StatusExternal marks a requirement whose tool a probe found installed
+	// without the package manager. It is satisfied, but the package manager
+	// cannot remove or upgrade it.
+	StatusExternal
+	// StatusUnknown marks a requirement whose package manager could not be
+	// queried. It counts as missing, installing it again does no harm.
+	StatusUnknown
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusInstalled:
+		return "installiert"
+	case StatusFailed:
+		return "fehlgeschlagen"
+	case StatusCancelled:
+		return "abgebrochen"
+	case StatusOutdated:
+		return "Update verfügbar"
+	case StatusTooOld:
+		return "installiert, aber zu alt"
+	case StatusRebootRequired:
+		return "Neustart erforderlich"
+	case StatusExternal:
+		return "außerhalb des Paketmanagers installiert"
+	case StatusUnknown:
+		return "Status unbekannt"
+	}
+	return "nicht installiert"
+}
+
+// Installed reports whether the package of the requirement is installed, in
+// whatever version. A change waiting for a reboot counts as installed, so it
+// is neither installed nor removed again.
+func (s Status) Installed() bool {
+	return s == StatusInstalled || s == StatusOutdated || s == StatusTooOld || s == StatusRebootRequired
+}
+
+// Satisfied reports whether the requirement is installed in an acceptable
+// version.
+func (s Status) Satisfied() bool {
+	return s == StatusInstalled || s == StatusOutdated || s == StatusRebootRequired || s == StatusExternal
+}
+
+// statusFromPackage maps the queried status of a package, checking the
+// installed version against constraint if there is one.
+func statusFromPackage(status packagemanager.PackageStatus, scheme version.Scheme, constraint *version.Constraint) Status {
+	switch {
+	case !status.Installed:
+		return StatusMissing
+	case constraint != nil && !constraint.Check(scheme, status.Version):
+		return StatusTooOld
+	case status.Outdated(scheme):
+		return StatusOutdated
+	case status.Installed:
+		return StatusInstalled
+	}
+	return StatusMissing
+}
+
+// statusFromError maps the result of an install operation to a status. Only an
+// explicit cancellation counts as cancelled, a timeout is a failure.
+func statusFromError(err error) Status {
+	switch {
+	case err == nil:
+		return StatusInstalled
+	case errors.Is(err, context.Canceled):
+		return StatusCancelled
+	}
+	return StatusFailed
+}
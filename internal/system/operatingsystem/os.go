@@ -1,9 +1,16 @@
 package operatingsystem
 
 type OS struct {
-	ID      string
+	ID string
+	// IDLike lists the distributions the system derives from, closest
+	// first, as given by ID_LIKE in os-release.
+	IDLike  []string
 	Name    string
 	Version string
+	// OSTree is set on image based systems like Fedora Silverblue, where
+	// packages are layered onto the image and only take effect after a
+	// reboot.
+	OSTree bool
 }
 
 func Info() (*OS, error) {
@@ -16,7 +16,12 @@ func platformInfo() (*OS, error) {
 	}
 
 	osRelease, _ := os.ReadFile("/etc/os-release")
-	return parseOsRelease(string(osRelease)), nil
+	result := parseOsRelease(string(osRelease))
+
+	// ostree creates this file on every system it booted.
+	_, err = os.Stat("/run/ostree-booted")
+	result.OSTree = err == nil
+	return result, nil
 }
 
 func parseOsRelease(osRelease string) *OS {
@@ -35,6 +40,8 @@ func parseOsRelease(osRelease string) *OS {
 		switch splitLine[0] {
 		case "ID":
 			result.ID = strings.ToLower(strings.Trim(splitLine[1], "\""))
+		case "ID_LIKE":
+			result.IDLike = strings.Fields(strings.ToLower(strings.Trim(splitLine[1], "\"")))
 		case "NAME":
 			result.Name = strings.Trim(splitLine[1], "\"")
 		case "VERSION_ID":
@@ -0,0 +1,128 @@
+//go:build linux
+// +build linux
+
+package packagemanager
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+// Apk is the package manager of Alpine Linux.
+type Apk struct {
+	name   string
+	osid   string
+	runner runner.Runner
+}
+
+func NewApk(osid string, r runner.Runner) *Apk {
+	return &Apk{
+		name:   "apk",
+		osid:   osid,
+		runner: r,
+	}
+}
+
+func (a *Apk) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "apk",
+		Args:    append([]string{"add", "--"}, nativeNames(pkgs, a.name)...),
+		Elevate: true,
+	}
+}
+
+func (a *Apk) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "apk",
+		Args:    append([]string{"del", "--"}, nativeNames(pkgs, a.name)...),
+		Elevate: true,
+	}
+}
+
+func (a *Apk) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "apk",
+		Args:    append([]string{"add", "--upgrade", "--"}, nativeNames(pkgs, a.name)...),
+		Elevate: true,
+	}
+}
+
+func (a *Apk) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, a.name)
+}
+
+func (a *Apk) Name() string {
+	return a.name
+}
+
+func (a *Apk) VersionScheme() version.Scheme {
+	return version.Apk
+}
+
+// QueryPackages asks apk policy for all packages at once. It lists every
+// version of a package with the repositories it is in, unknown packages are
+// left out.
+func (a *Apk) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, a.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, a.name, nil, nil), nil
+	}
+
+	stdout, _, err := runner.Output(ctx, a.runner, runner.Command{
+		Name: "apk",
+		Args: append([]string{"policy", "--"}, names...),
+	})
+	if err != nil && !runner.IsExitError(err) {
+		return nil, err
+	}
+	installed, available := a.parsePolicy(string(stdout))
+
+	return collectStatuses(pkgs, a.name, installed, available), nil
+}
+
+func (a *Apk) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, a, pkg)
+	return status.Installed, err
+}
+
+func (a *Apk) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, a, pkg)
+	return status.Available, err
+}
+
+// parsePolicy reads the output of apk policy. The installed version is listed
+// with the database of installed packages, the candidate is the newest version
+// found in a repository.
+//
+//	git policy:
+//	  2.47.1-r0:
+//	    lib/apk/db/installed
+//	    https://dl-cdn.alpinelinux.org/alpine/v3.21/main
+func (a *Apk) parsePolicy(output string) (installed map[string]string, available map[string]string) {
+	installed = map[string]string{}
+	available = map[string]string{}
+	name, current := "", ""
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case !strings.HasPrefix(line, " "):
+			name, _, _ = strings.Cut(trimmed, " ")
+			current = ""
+		case strings.HasSuffix(trimmed, ":"):
+			current = strings.TrimSuffix(trimmed, ":")
+		case name == "" || current == "":
+		case trimmed == "lib/apk/db/installed":
+			installed[name] = current
+		default:
+			if candidate, ok := available[name]; !ok || version.Apk.Compare(current, candidate) > 0 {
+				available[name] = current
+			}
+		}
+	}
+	return installed, available
+}
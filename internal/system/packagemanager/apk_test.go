@@ -0,0 +1,34 @@
+//go:build linux
+
+package packagemanager
+
+import "testing"
+
+func TestApkQueryPackages(t *testing.T) {
+	fake := loadFixture(t, "apk/alpine-3.21.json")
+	checkQuery(t, NewApk("alpine", fake), fake, []queryTest{
+		{"git", "git", PackageStatus{true, true, "2.47.1-r0", "2.47.1-r0"}},
+		{"openjdk", "openjdk17-jdk", PackageStatus{true, true, "17.0.12_p7-r0", "17.0.13_p11-r0"}},
+		{"podman", "podman", PackageStatus{true, false, "5.3.1-r1", "5.3.1-r1"}},
+		{"vscode", "code", PackageStatus{false, false, "", ""}},
+	}, 1)
+}
+
+func TestApkCommands(t *testing.T) {
+	apk := NewApk("alpine", nil)
+	pkgs := []*Package{testPackage("maven", "apk", "maven"), testPackage("podman", "apk", "podman")}
+
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{apk.InstallCommand(pkgs...).String(), "apk add -- maven podman"},
+		{apk.RemoveCommand(pkgs...).String(), "apk del -- maven podman"},
+		{apk.UpgradeCommand(pkgs...).String(), "apk add --upgrade -- maven podman"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("command = %q, want %q", tt.got, tt.want)
+		}
+	}
+}
@@ -4,78 +4,205 @@
 package packagemanager
 
 import (
-	"bytes"
+	"context"
+	"fmt"
 	"os"
-	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
 )
 
 type Apt struct {
-	name string
-	osid string
+	name   string
+	osid   string
+	runner runner.Runner
 }
 
-func NewApt(osid string) *Apt {
+func NewApt(osid string, r runner.Runner) *Apt {
 	return &Apt{
-		name: "apt",
-		osid: osid,
+		name:   "apt",
+		osid:   osid,
+		runner: r,
+	}
+}
+
+func (a *Apt) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "apt",
+		Args:    append([]string{"install", "-y", "--"}, nativeNames(pkgs, a.name)...),
+		Elevate: true,
+	}
+}
+
+func (a *Apt) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "apt",
+		Args:    append([]string{"remove", "-y", "--"}, nativeNames(pkgs, a.name)...),
+		Elevate: true,
 	}
 }
 
-func (a *Apt) Packages() packagemap {
-	universalPackages := GenerateUniversalPackages()
-	aptSpecificPackages := packagemap{}
-	for key, value := range aptSpecificPackages {
-		universalPackages[key] = value
+func (a *Apt) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "apt",
+		Args:    append([]string{"install", "--only-upgrade", "-y", "--"}, nativeNames(pkgs, a.name)...),
+		Elevate: true,
 	}
+}
+
+// aptStatus matches the lines apt prints to its Status-Fd while downloading
+// and while dpkg runs. Names of packages may contain colons, the percentage is
+// the first number after them.
+var aptStatus = regexp.MustCompile(`^(dlstatus|pmstatus):.*?:([0-9.]+):`)
+
+// ReportProgress makes apt print status lines to stdout.
+func (a *Apt) ReportProgress(cmd runner.Command) runner.Command {
+	cmd.Args = append([]string{"-o", "APT::Status-Fd=1"}, cmd.Args...)
+	return cmd
+}
+
+// Progress counts the download as the first half of the transaction and the
+// run of dpkg as the second.
+func (a *Apt) Progress(line string) (float64, bool) {
+	match := aptStatus.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false
+	}
+	percent, err := strconv.ParseFloat(match[2], 64)
+	if err != nil || percent > 100 {
+		return 0, false
+	}
+	if match[1] == "dlstatus" {
+		return percent / 200, true
+	}
+	return 0.5 + percent/200, true
+}
+
+func (a *Apt) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, a.name)
+}
+
+func (a *Apt) keyringPath(repo *Repository) string {
+	return "/etc/apt/keyrings/" + repo.Name + ".asc"
+}
+
+func (a *Apt) sourcesPath(repo *Repository) string {
+	return "/etc/apt/sources.list.d/" + repo.Name + ".sources"
+}
 
-	return universalPackages
+// AddRepository installs the signing key into its own keyring, which the
+// deb822 sources file refers to with Signed-By, so the key is only trusted
+// for this repository.
+func (a *Apt) AddRepository(repo *Repository) *RepositorySetup {
+	sources := fmt.Sprintf("Types: deb\nURIs: %s\nSuites: %s\nComponents: %s\nSigned-By: %s\n",
+		repo.URL, repo.Suite, strings.Join(repo.Components, " "), a.keyringPath(repo))
+
+	return &RepositorySetup{
+		Repository: repo,
+		Files: []RepositoryFile{
+			{Path: a.keyringPath(repo), URL: repo.KeyURL},
+			{Path: a.sourcesPath(repo), Content: sources},
+		},
+		Commands: []runner.Command{{Name: "apt-get", Args: []string{"update"}, Elevate: true}},
+	}
 }
 
-func (a *Apt) InstallCommand(pkg *Package) string {
-	if pkg.SystemPackage == false {
-		return pkg.NativePackageName[a.osid]
+func (a *Apt) RemoveRepository(repo *Repository) *RepositorySetup {
+	return &RepositorySetup{
+		Repository: repo,
+		Commands: []runner.Command{
+			removeFiles(a.sourcesPath(repo), a.keyringPath(repo)),
+			{Name: "apt-get", Args: []string{"update"}, Elevate: true},
+		},
 	}
-	return "apt install " + pkg.NativePackageName[a.name] + " -y"
+}
+
+func (a *Apt) RepositoryConfigured(repo *Repository) bool {
+	_, err := os.Stat(a.sourcesPath(repo))
+	return err == nil
 }
 
 func (a *Apt) Name() string {
 	return a.name
 }
 
-func (a *Apt) PackageInstalled(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
+func (a *Apt) VersionScheme() version.Scheme {
+	return version.Debian
+}
+
+func (a *Apt) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, a.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, a.name, nil, nil), nil
 	}
-	cmd := exec.Command("apt", "list", "-qq", pkg.NativePackageName[a.name])
-	var stdo, stde bytes.Buffer
-	cmd.Stdout = &stdo
-	cmd.Stderr = &stde
-	cmd.Env = append(os.Environ(), "LANGUAGE=en")
-	err := cmd.Run()
-	return strings.Contains(stdo.String(), "[installed]"), err
-}
-
-func (a *Apt) PackageAvailable(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
+
+	// dpkg-query exits with 1 if any of the names is unknown to dpkg.
+	stdout, _, err := runner.Output(ctx, a.runner, runner.Command{
+		Name: "dpkg-query",
+		Args: append([]string{"-W", `--showformat=${Package}\t${db:Status-Abbrev}\t${Version}\n`}, names...),
+	})
+	if err != nil && !runner.IsExitError(err) {
+		return nil, err
 	}
-	stdout, err := exec.Command("apt", "list", "-qq", pkg.NativePackageName[a.name]).Output()
-	output := a.removeEscapeSequences(string(stdout))
-	installed := strings.HasPrefix(output, pkg.Name)
-	a.getPackageVersion(pkg, output)
-	return installed, err
+	installed := a.parseDpkgQuery(string(stdout))
+
+	stdout, _, err = runner.Output(ctx, a.runner, runner.Command{
+		Name: "apt-cache",
+		Args: append([]string{"policy"}, names...),
+		Env:  []string{"LC_ALL=C"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	available := a.parsePolicy(string(stdout))
+
+	return collectStatuses(pkgs, a.name, installed, available), nil
+}
+
+func (a *Apt) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, a, pkg)
+	return status.Installed, err
 }
 
-func (a *Apt) removeEscapeSequences(in string) string {
-	escapechars, _ := regexp.Compile(`\x1B(?:[@-Z\\-_]|\[[0-?]*[ -/]*[@-~])`)
-	return escapechars.ReplaceAllString(in, "")
+func (a *Apt) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, a, pkg)
+	return status.Available, err
 }
 
-func (a *Apt) getPackageVersion(pkg *Package, output string) {
-	splitOutput := strings.Split(output, " ")
-	if len(splitOutput) > 1 {
-		pkg.Version = splitOutput[1]
+// parseDpkgQuery returns the versions of all installed packages. The second
+// letter of the status abbreviation is "i" for installed packages, removed
+// packages with leftover configuration show up as "rc".
+func (a *Apt) parseDpkgQuery(output string) map[string]string {
+	installed := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || len(fields[1]) < 2 || fields[1][1] != 'i' {
+			continue
+		}
+		installed[fields[0]] = fields[2]
+	}
+	return installed
+}
+
+// parsePolicy returns the candidate versions from the output of apt-cache
+// policy. Packages without a candidate are left out.
+func (a *Apt) parsePolicy(output string) map[string]string {
+	available := map[string]string{}
+	current := ""
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, " ") && strings.HasSuffix(line, ":") {
+			current = strings.TrimSuffix(line, ":")
+			continue
+		}
+
+		candidate, found := strings.CutPrefix(strings.TrimSpace(line), "Candidate:")
+		candidate = strings.TrimSpace(candidate)
+		if found && current != "" && candidate != "(none)" {
+			available[current] = candidate
+		}
 	}
+	return available
 }
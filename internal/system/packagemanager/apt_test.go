@@ -0,0 +1,167 @@
+//go:build linux
+
+package packagemanager
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+func TestAptQueryPackages(t *testing.T) {
+	tests := map[string][]queryTest{
+		"apt/debian-12.json": {
+			{"git", "git", PackageStatus{true, true, "1:2.39.5-0+deb12u1", "1:2.39.5-0+deb12u1"}},
+			{"openjdk", "openjdk-17-jdk", PackageStatus{true, false, "17.0.13+11-2~deb12u1", "17.0.13+11-2~deb12u1"}},
+			{"podman", "podman", PackageStatus{true, true, "4.3.1+ds1-8+deb12u1", "4.3.1+ds1-8+deb12u1"}},
+			{"vscode", "code", PackageStatus{false, false, "", ""}},
+		},
+		"apt/ubuntu-24.04.json": {
+			{"git", "git", PackageStatus{true, true, "1:2.43.0-1ubuntu7.1", "1:2.43.0-1ubuntu7.1"}},
+			{"openjdk", "openjdk-17-jdk", PackageStatus{true, false, "17.0.13+11-2ubuntu1~24.04", "17.0.13+11-2ubuntu1~24.04"}},
+			{"vscode", "code", PackageStatus{true, true, "1.95.3-1731513102", "1.96.0-1733888194"}},
+			{"podman", "podman", PackageStatus{true, false, "4.9.3+ds1-1build2", "4.9.3+ds1-1build2"}},
+		},
+	}
+
+	for fixture, queries := range tests {
+		t.Run(fixture, func(t *testing.T) {
+			fake := loadFixture(t, fixture)
+			checkQuery(t, NewApt("debian", fake), fake, queries, 2)
+		})
+	}
+}
+
+func TestAptSinglePackageQueries(t *testing.T) {
+	apt := NewApt("debian", loadFixture(t, "apt/debian-12.json"))
+	pkg := testPackage("git", "apt", "git")
+
+	available, err := apt.PackageAvailable(context.Background(), pkg)
+	if err != nil || !available {
+		t.Errorf("PackageAvailable() = %v, %v, want true, nil", available, err)
+	}
+	installed, err := apt.PackageInstalled(context.Background(), pkg)
+	if err != nil || !installed {
+		t.Errorf("PackageInstalled() = %v, %v, want true, nil", installed, err)
+	}
+}
+
+func TestAptParsePolicy(t *testing.T) {
+	fake := loadFixture(t, "apt/ubuntu-24.04.json")
+	output := fake.Responses["apt-cache policy git openjdk-17-jdk code podman"].Stdout
+
+	want := map[string]string{
+		"git":            "1:2.43.0-1ubuntu7.1",
+		"openjdk-17-jdk": "17.0.13+11-2ubuntu1~24.04",
+		"code":           "1.96.0-1733888194",
+		"podman":         "4.9.3+ds1-1build2",
+	}
+	got := NewApt("ubuntu", nil).parsePolicy(output)
+	if len(got) != len(want) {
+		t.Errorf("parsePolicy() = %v, want %v", got, want)
+	}
+	for name, version := range want {
+		if got[name] != version {
+			t.Errorf("parsePolicy()[%s] = %q, want %q", name, got[name], version)
+		}
+	}
+}
+
+func TestAptParseDpkgQuery(t *testing.T) {
+	tests := []struct {
+		output string
+		want   map[string]string
+	}{
+		{"git\tii \t1:2.39.5-0+deb12u1\n", map[string]string{"git": "1:2.39.5-0+deb12u1"}},
+		{"code\thi \t1.95.3-1731513102\n", map[string]string{"code": "1.95.3-1731513102"}},
+		{"openjdk-17-jdk\trc \t17.0.12+7-2~deb12u1\n", map[string]string{}},
+		{"podman\tun \t\n", map[string]string{}},
+		{"", map[string]string{}},
+	}
+
+	apt := NewApt("debian", nil)
+	for _, tt := range tests {
+		got := apt.parseDpkgQuery(tt.output)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseDpkgQuery(%q) = %v, want %v", tt.output, got, tt.want)
+			continue
+		}
+		for name, version := range tt.want {
+			if got[name] != version {
+				t.Errorf("parseDpkgQuery(%q)[%s] = %q, want %q", tt.output, name, got[name], version)
+			}
+		}
+	}
+}
+
+func TestAptInstallCommand(t *testing.T) {
+	apt := NewApt("debian", nil)
+	got := apt.InstallCommand(
+		testPackage("git", "apt", "git"),
+		testPackage("openjdk", "apt", "openjdk-17-jdk"),
+		testPackage("vscode", "pacman", "code"),
+	)
+	want := runner.Command{
+		Name:    "apt",
+		Args:    []string{"install", "-y", "--", "git", "openjdk-17-jdk"},
+		Elevate: true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InstallCommand() = %#v, want %#v", got, want)
+	}
+}
+
+func TestAptAddRepository(t *testing.T) {
+	repo := &Repository{
+		Name:       "vscode",
+		URL:        "https://packages.microsoft.com/repos/code",
+		KeyURL:     "https://packages.microsoft.com/keys/microsoft.asc",
+		Suite:      "stable",
+		Components: []string{"main"},
+	}
+	setup := NewApt("debian", nil).AddRepository(repo)
+
+	want := []RepositoryFile{
+		{Path: "/etc/apt/keyrings/vscode.asc", URL: "https://packages.microsoft.com/keys/microsoft.asc"},
+		{Path: "/etc/apt/sources.list.d/vscode.sources", Content: "Types: deb\n" +
+			"URIs: https://packages.microsoft.com/repos/code\n" +
+			"Suites: stable\n" +
+			"Components: main\n" +
+			"Signed-By: /etc/apt/keyrings/vscode.asc\n"},
+	}
+	if !reflect.DeepEqual(setup.Files, want) {
+		t.Errorf("Files = %#v, want %#v", setup.Files, want)
+	}
+	if len(setup.Commands) != 1 || setup.Commands[0].Shell() != "apt-get update" || !setup.Commands[0].Elevate {
+		t.Errorf("Commands = %#v, want elevated apt-get update", setup.Commands)
+	}
+}
+
+func TestAptProgress(t *testing.T) {
+	apt := NewApt("debian", nil)
+	cmd := apt.ReportProgress(apt.InstallCommand(testPackage("git", "apt", "git")))
+	if got, want := cmd.String(), "apt -o APT::Status-Fd=1 install -y -- git"; got != want {
+		t.Errorf("ReportProgress() = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		line string
+		want float64
+		ok   bool
+	}{
+		{"dlstatus:1:0:Retrieving file 1 of 3", 0, true},
+		{"dlstatus:2:50.0000:Retrieving file 2 of 3", 0.25, true},
+		{"pmstatus:git:50:Installing git (amd64)", 0.75, true},
+		{"pmstatus:libc6:amd64:100:Installed libc6 (amd64)", 1, true},
+		{"Setting up git (1:2.39.5-0+deb12u1) ...", 0, false},
+		{"pmstatus:git:200:Installing git", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := apt.Progress(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Progress(%q) = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package packagemanager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+// maxChecksumFileSize limits downloads of checksum files, signatures and keys.
+const maxChecksumFileSize = 1 << 20
+
+// fetch returns the path of archive in the cache directory and its SHA-256
+// digest. An archive already in the cache is used if it passes verification,
+// otherwise it is downloaded again.
+func (u *UserPrefix) fetch(ctx context.Context, archive *Archive) (string, string, error) {
+	want, newHash, err := u.checksum(ctx, archive)
+	if err != nil {
+		return "", "", err
+	}
+
+	name, err := cacheName(archive.URL)
+	if err != nil {
+		return "", "", err
+	}
+	cached := filepath.Join(u.cacheDir, name)
+
+	digest, err := verifyFile(cached, want, newHash)
+	if err != nil {
+		if err := os.MkdirAll(u.cacheDir, 0o755); err != nil {
+			return "", "", err
+		}
+		if digest, err = u.downloadVerified(ctx, archive.URL, cached, want, newHash); err != nil {
+			return "", "", err
+		}
+	}
+
+	if archive.SignatureURL != "" {
+		if err := u.verifySignature(ctx, archive, cached); err != nil {
+			return "", "", err
+		}
+	}
+	return cached, digest, nil
+}
+
+// checksum returns the expected digest of archive and the hash it was made
+// with. The digest is empty if the archive is only verified by its signature.
+func (u *UserPrefix) checksum(ctx context.Context, archive *Archive) (string, func() hash.Hash, error) {
+	digest := archive.SHA256
+	if digest == "" && archive.ChecksumURL != "" {
+		var buf bytes.Buffer
+		if err := u.download(ctx, archive.ChecksumURL, &buf, maxChecksumFileSize); err != nil {
+			return "", nil, err
+		}
+		// Checksum files hold the digest either alone or followed by the
+		// file name.
+		fields := strings.Fields(buf.String())
+		if len(fields) == 0 {
+			return "", nil, fmt.Errorf("empty checksum file %s", archive.ChecksumURL)
+		}
+		digest = fields[0]
+	}
+
+	digest = strings.ToLower(digest)
+	switch len(digest) {
+	case 0:
+		if archive.SignatureURL == "" {
+			return "", nil, fmt.Errorf("no checksum or signature for %s", archive.URL)
+		}
+		return "", nil, nil
+	case sha256.Size * 2:
+		return digest, sha256.New, nil
+	case sha512.Size * 2:
+		return digest, sha512.New, nil
+	}
+	return "", nil, fmt.Errorf("invalid checksum %q for %s", digest, archive.URL)
+}
+
+// cacheName returns the file name of the archive at rawURL in the cache, which
+// is the last element of its path.
+func cacheName(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("no file name in %s", rawURL)
+	}
+	return name, nil
+}
+
+// verifyFile returns the SHA-256 digest of the file at path after checking
+// it against want, if given.
+func verifyFile(path string, want string, newHash func() hash.Hash) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	digest, err := digestOf(file, want, newHash)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+	return digest, nil
+}
+
+// digestOf reads r and returns its SHA-256 digest after checking it against
+// want.
+func digestOf(r io.Reader, want string, newHash func() hash.Hash) (string, error) {
+	sum := sha256.New()
+	writers := []io.Writer{sum}
+	var check hash.Hash
+	if want != "" {
+		check = newHash()
+		writers = append(writers, check)
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return "", err
+	}
+	if check != nil {
+		if got := hex.EncodeToString(check.Sum(nil)); got != want {
+			return "", fmt.Errorf("checksum mismatch: got %s, want %s", got, want)
+		}
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// downloadVerified downloads rawURL to path. The file is only moved into
+// place once it passed verification.
+func (u *UserPrefix) downloadVerified(ctx context.Context, rawURL string, path string, want string, newHash func() hash.Hash) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := u.download(ctx, rawURL, tmp, 0); err != nil {
+		return "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	digest, err := digestOf(tmp, want, newHash)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", rawURL, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return digest, os.Rename(tmp.Name(), path)
+}
+
+// download writes the content at rawURL to w. A limit above zero restricts
+// the size of the content.
+func (u *UserPrefix) download(ctx context.Context, rawURL string, w io.Writer, limit int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download of %s failed: %s", rawURL, resp.Status)
+	}
+
+	if limit <= 0 {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, limit+1))
+	if err == nil && n > limit {
+		err = fmt.Errorf("download of %s failed: file too large", rawURL)
+	}
+	return err
+}
+
+// verifySignature checks the detached signature of the archive at path with
+// gpgv. The key is imported into a temporary keyring, so the keyring of the
+// user is neither used nor changed.
+func (u *UserPrefix) verifySignature(ctx context.Context, archive *Archive, path string) error {
+	if archive.KeyURL == "" {
+		return fmt.Errorf("no key for the signature of %s", archive.URL)
+	}
+
+	home, err := os.MkdirTemp("", "jws-gpg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+
+	files := map[string]string{"key": archive.KeyURL, "signature": archive.SignatureURL}
+	for name, rawURL := range files {
+		var buf bytes.Buffer
+		if err := u.download(ctx, rawURL, &buf, maxChecksumFileSize); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(home, name), buf.Bytes(), 0o600); err != nil {
+			return err
+		}
+	}
+
+	keyring := filepath.Join(home, "keyring.gpg")
+	commands := []runner.Command{
+		{
+			Name: "gpg",
+			Args: []string{"--batch", "--homedir", home, "--no-default-keyring", "--keyring", keyring, "--import", filepath.Join(home, "key")},
+		},
+		{
+			Name: "gpgv",
+			Args: []string{"--homedir", home, "--keyring", keyring, filepath.Join(home, "signature"), path},
+		},
+	}
+	for _, cmd := range commands {
+		if _, stderr, err := runner.Output(ctx, u.runner, cmd); err != nil {
+			return fmt.Errorf("signature of %s: %s: %v: %s", archive.URL, cmd.Name, err, bytes.TrimSpace(stderr))
+		}
+	}
+	return nil
+}
+
+// extractAtomic extracts the archive at path into dir. The archive is
+// extracted next to dir first and then moved into place, so dir never holds
+// a partial installation.
+func extractAtomic(path string, rawURL string, dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+"-")
+	if err != nil {
+		return err
+	}
+	if err := extract(path, rawURL, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	// A reinstall of the same version replaces the existing directory.
+	if _, err := os.Stat(dir); err == nil {
+		old := tmp + "-old"
+		if err := os.Rename(dir, old); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+		defer os.RemoveAll(old)
+	}
+	return os.Rename(tmp, dir)
+}
+
+// extract unpacks the tar.gz or zip archive at path into dir, without its
+// top-level directory.
+func extract(path string, rawURL string, dir string) error {
+	e := &extractor{dir: dir, links: map[string]bool{}}
+	var err error
+	if strings.HasSuffix(rawURL, ".zip") {
+		err = e.zip(path)
+	} else {
+		err = e.tarGz(path)
+	}
+	if err != nil {
+		return err
+	}
+	return e.checkLinks()
+}
+
+// stripTop returns name without its first component. Names that would end up
+// outside of the target directory are rejected.
+func stripTop(name string) (string, error) {
+	_, rest, _ := strings.Cut(strings.TrimPrefix(filepath.ToSlash(name), "./"), "/")
+	if rest == "" {
+		return "", nil
+	}
+	if !filepath.IsLocal(rest) {
+		return "", fmt.Errorf("invalid path in archive: %s", name)
+	}
+	return filepath.FromSlash(rest), nil
+}
+
+// extractor writes the entries of an archive into dir. Archives are
+// downloaded, so no entry may end up outside of dir, neither by its name nor
+// by way of a link the archive created before.
+type extractor struct {
+	dir string
+	// links holds the names of the links created so far.
+	links map[string]bool
+}
+
+// throughLink reports whether path is or passes through one of the links
+// created so far. Every component is checked before a following ".." could
+// clean it away, as the link would be followed there.
+func (e *extractor) throughLink(path string) bool {
+	current := ""
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		current = filepath.Join(current, part)
+		if e.links[current] {
+			return true
+		}
+	}
+	return false
+}
+
+// target returns the path an entry called name is written to, or "" for the
+// top-level directory.
+func (e *extractor) target(name string) (string, error) {
+	rel, err := stripTop(name)
+	if err != nil || rel == "" {
+		return "", err
+	}
+	if e.throughLink(rel) {
+		return "", fmt.Errorf("invalid path in archive: %s passes through a link", name)
+	}
+	return filepath.Join(e.dir, rel), nil
+}
+
+// symlink creates the link at target. The link has to stay within dir without
+// following the links created before.
+func (e *extractor) symlink(name string, target string, linkname string) error {
+	rel, _ := filepath.Rel(e.dir, target)
+	if filepath.IsAbs(linkname) || !filepath.IsLocal(filepath.Join(filepath.Dir(rel), linkname)) ||
+		e.throughLink(filepath.Dir(rel)+"/"+linkname) {
+		return fmt.Errorf("invalid link in archive: %s -> %s", name, linkname)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if err := os.Symlink(linkname, target); err != nil {
+		return err
+	}
+	e.links[rel] = true
+	return nil
+}
+
+// checkLinks makes sure every link resolves to a path within dir, as links to
+// links created later are not caught by symlink. Dangling links are left
+// alone.
+func (e *extractor) checkLinks() error {
+	root, err := filepath.EvalSymlinks(e.dir)
+	if err != nil {
+		return err
+	}
+	for rel := range e.links {
+		resolved, err := filepath.EvalSymlinks(filepath.Join(e.dir, rel))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if inside, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(inside) {
+			return fmt.Errorf("invalid link in archive: %s points outside of the archive", rel)
+		}
+	}
+	return nil
+}
+
+func (e *extractor) tarGz(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := e.target(header.Name)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0o755)
+		case tar.TypeReg:
+			err = writeFile(target, tr, header.FileInfo().Mode())
+		case tar.TypeSymlink:
+			err = e.symlink(header.Name, target, header.Linkname)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (e *extractor) zip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		target, err := e.target(file.Name)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+		if file.Mode()&fs.ModeSymlink != 0 {
+			// The content of a link entry is the path it points to.
+			var linkname []byte
+			linkname, err = io.ReadAll(io.LimitReader(r, 4096))
+			if err == nil {
+				err = e.symlink(file.Name, target, string(linkname))
+			}
+		} else {
+			err = writeFile(target, r, file.Mode())
+		}
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
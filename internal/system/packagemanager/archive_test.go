@@ -0,0 +1,299 @@
+package packagemanager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+func TestFetchVerifiesChecksum(t *testing.T) {
+	data := tarGz(t, "gradle-8.11.1", map[string]string{"bin/gradle": "#!/bin/sh\n"})
+	sum := sha512.Sum512(data)
+	server, _ := archiveServer(t, map[string][]byte{
+		"/gradle.tar.gz":        data,
+		"/gradle.tar.gz.sha256": []byte(sha256Hex(data) + "  gradle.tar.gz\n"),
+		"/gradle.tar.gz.sha512": []byte(hex.EncodeToString(sum[:]) + "\n"),
+		"/bad.sha256":           []byte(strings.Repeat("0", 64)),
+	})
+
+	tests := []struct {
+		name    string
+		archive Archive
+		wantErr string
+	}{
+		{"sha256", Archive{SHA256: strings.ToUpper(sha256Hex(data))}, ""},
+		{"checksum file", Archive{ChecksumURL: server.URL + "/gradle.tar.gz.sha256"}, ""},
+		{"sha512 file", Archive{ChecksumURL: server.URL + "/gradle.tar.gz.sha512"}, ""},
+		{"mismatch", Archive{ChecksumURL: server.URL + "/bad.sha256"}, "checksum mismatch"},
+		{"missing checksum file", Archive{ChecksumURL: server.URL + "/missing.sha256"}, "404"},
+		{"invalid checksum", Archive{SHA256: "abc"}, "invalid checksum"},
+		{"unverified", Archive{}, "no checksum or signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := t.TempDir()
+			prefix := NewUserPrefix(t.TempDir(), cache, nil)
+			tt.archive.URL = server.URL + "/gradle.tar.gz"
+
+			path, digest, err := prefix.fetch(context.Background(), &tt.archive)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("fetch() error = %v, want %q", err, tt.wantErr)
+				}
+				if _, err := os.Stat(filepath.Join(cache, "gradle.tar.gz")); !os.IsNotExist(err) {
+					t.Errorf("unverified archive was kept in the cache: %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("fetch() error = %v", err)
+			}
+			if path != filepath.Join(cache, "gradle.tar.gz") || digest != sha256Hex(data) {
+				t.Errorf("fetch() = %q, %q, want %q, %q", path, digest, filepath.Join(cache, "gradle.tar.gz"), sha256Hex(data))
+			}
+		})
+	}
+}
+
+func TestFetchUsesCache(t *testing.T) {
+	data := tarGz(t, "apache-maven-3.9.9", map[string]string{"bin/mvn": "#!/bin/sh\n"})
+	server, requests := archiveServer(t, map[string][]byte{"/maven.tar.gz": data})
+
+	cache := t.TempDir()
+	cached := filepath.Join(cache, "maven.tar.gz")
+	prefix := NewUserPrefix(t.TempDir(), cache, nil)
+	archive := &Archive{URL: server.URL + "/dist/maven.tar.gz", SHA256: sha256Hex(data)}
+
+	// An archive put into the cache in advance is used without downloading
+	// anything, even if the server does not have it.
+	if err := os.WriteFile(cached, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := prefix.fetch(context.Background(), archive); err != nil {
+		t.Fatalf("fetch() with a filled cache error = %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("fetch() with a filled cache made requests: %v", requests)
+	}
+
+	// A damaged archive in the cache is downloaded again.
+	if err := os.WriteFile(cached, []byte("damaged"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive.URL = server.URL + "/maven.tar.gz"
+	if _, _, err := prefix.fetch(context.Background(), archive); err != nil {
+		t.Fatalf("fetch() with a damaged cache error = %v", err)
+	}
+	if got, _ := os.ReadFile(cached); string(got) != string(data) {
+		t.Error("damaged archive in the cache was not replaced")
+	}
+}
+
+// gpgRunner records the gpg commands and fails gpgv if fail is set.
+type gpgRunner struct {
+	calls []runner.Command
+	fail  bool
+}
+
+func (g *gpgRunner) Run(ctx context.Context, cmd runner.Command, stdin io.Reader, stdout, stderr io.Writer) error {
+	g.calls = append(g.calls, cmd)
+	if g.fail && cmd.Name == "gpgv" {
+		io.WriteString(stderr, "gpgv: BAD signature\n")
+		return &runner.ExitError{Code: 1}
+	}
+	return nil
+}
+
+func (g *gpgRunner) LookPath(name string) (string, error) {
+	return "/usr/bin/" + name, nil
+}
+
+func TestFetchVerifiesSignature(t *testing.T) {
+	data := tarGz(t, "apache-maven-3.9.9", map[string]string{"bin/mvn": "#!/bin/sh\n"})
+	server, _ := archiveServer(t, map[string][]byte{
+		"/maven.tar.gz":     data,
+		"/maven.tar.gz.asc": []byte("signature"),
+		"/KEYS":             []byte("key"),
+	})
+	archive := &Archive{
+		URL:          server.URL + "/maven.tar.gz",
+		SignatureURL: server.URL + "/maven.tar.gz.asc",
+		KeyURL:       server.URL + "/KEYS",
+	}
+
+	gpg := &gpgRunner{}
+	cache := t.TempDir()
+	if _, _, err := NewUserPrefix(t.TempDir(), cache, gpg).fetch(context.Background(), archive); err != nil {
+		t.Fatalf("fetch() error = %v", err)
+	}
+	if len(gpg.calls) != 2 || gpg.calls[0].Name != "gpg" || gpg.calls[1].Name != "gpgv" {
+		t.Fatalf("commands = %v, want gpg --import and gpgv", gpg.calls)
+	}
+	if args := gpg.calls[1].Args; args[len(args)-1] != filepath.Join(cache, "maven.tar.gz") {
+		t.Errorf("gpgv verified %q, want the cached archive", args[len(args)-1])
+	}
+
+	gpg = &gpgRunner{fail: true}
+	_, _, err := NewUserPrefix(t.TempDir(), t.TempDir(), gpg).fetch(context.Background(), archive)
+	if err == nil || !strings.Contains(err.Error(), "BAD signature") {
+		t.Errorf("fetch() error = %v, want BAD signature", err)
+	}
+}
+
+func TestExtractAtomic(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "maven", "3.9.9")
+	good := filepath.Join(t.TempDir(), "good.tar.gz")
+	if err := os.WriteFile(good, tarGz(t, "apache-maven-3.9.9", map[string]string{"lib/maven.jar": "new"}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(t.TempDir(), "bad.tar.gz")
+	if err := os.WriteFile(bad, tarGz(t, "apache-maven-3.9.9", map[string]string{"../../escape": "x"}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := extractAtomic(bad, "bad.tar.gz", dir); err == nil {
+		t.Fatal("extractAtomic() of an archive with an escaping path succeeded")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dir)); len(entries) != 0 {
+		t.Errorf("failed extraction left %d entries behind", len(entries))
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := extractAtomic(good, "good.tar.gz", dir); err != nil {
+			t.Fatalf("extractAtomic() error = %v", err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "lib", "maven.jar")); err != nil || string(data) != "new" {
+		t.Errorf("maven.jar = %q, %v", data, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dir)); len(entries) != 1 {
+		t.Errorf("reinstall left %d entries behind, want 1", len(entries))
+	}
+}
+
+// entry is an entry of a test archive, a link if linkname is set.
+type entry struct {
+	name     string
+	linkname string
+}
+
+func linkTarGz(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: "top/" + e.name, Mode: 0o644, Size: 1, Typeflag: tar.TypeReg}
+		if e.linkname != "" {
+			header = &tar.Header{Name: "top/" + e.name, Mode: 0o777, Linkname: e.linkname, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if e.linkname == "" {
+			tw.Write([]byte("x"))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func linkZip(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: "top/" + e.name}
+		content := "x"
+		header.SetMode(0o644)
+		if e.linkname != "" {
+			header.SetMode(os.ModeSymlink | 0o777)
+			content = e.linkname
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on Windows")
+	}
+
+	tests := []struct {
+		entries []entry
+		ok      bool
+	}{
+		{[]entry{{"bin/java", ""}, {"java", "bin/java"}, {"lib", "."}}, true},
+		{[]entry{{"escape", "../x"}}, false},
+		{[]entry{{"escape", "/etc/passwd"}}, false},
+		// Each link stays within the archive on its own, but the file is
+		// written two levels above it.
+		{[]entry{{"l1", "."}, {"l1/l2", ".."}, {"l1/l2/x", ""}}, false},
+		{[]entry{{"l1", "."}, {"l2", "l1/.."}}, false},
+		{[]entry{{"l1", "bin"}, {"l1", ""}}, false},
+		// The links only point outside once all of them exist.
+		{[]entry{{"a", "b/.."}, {"b", "c"}, {"c", "."}}, false},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{"tar.gz", "zip"} {
+			data := linkTarGz(t, tt.entries)
+			if format == "zip" {
+				data = linkZip(t, tt.entries)
+			}
+			path := filepath.Join(t.TempDir(), "archive."+format)
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			root := t.TempDir()
+			dir := filepath.Join(root, "dir")
+			if err := os.Mkdir(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			err := extract(path, path, dir)
+			if (err == nil) != tt.ok {
+				t.Errorf("extract(%s %v) error = %v, want ok %v", format, tt.entries, err, tt.ok)
+			}
+			if _, err := os.Stat(filepath.Join(root, "x")); err == nil {
+				t.Errorf("extract(%s %v) wrote outside of the directory", format, tt.entries)
+			}
+		}
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	os.WriteFile(path, linkZip(t, []entry{{"bin/java", ""}, {"java", "bin/java"}}), 0o644)
+	if err := extract(path, path, dir); err != nil {
+		t.Fatal(err)
+	}
+	if linkname, err := os.Readlink(filepath.Join(dir, "java")); err != nil || linkname != "bin/java" {
+		t.Errorf("link from zip = %q, %v, want bin/java", linkname, err)
+	}
+}
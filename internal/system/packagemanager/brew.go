@@ -1,126 +1,204 @@
-//go:build darwin
-// +build darwin
+//go:build darwin || linux
+// +build darwin linux
 
 package packagemanager
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"os/exec"
-	"regexp"
+	"runtime"
 	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
 )
 
+// Homebrew installs formulae, and on macOS casks, without root privileges.
+// On Linux it complements the package manager of the distribution.
 type Homebrew struct {
-	name string
-	osid string
+	name   string
+	osid   string
+	runner runner.Runner
+	// command is the brew executable, a full path if it is not on the PATH.
+	command string
+	// casks enables casks, which only exist on macOS.
+	casks bool
 }
 
-func NewHomebrew(osid string) *Homebrew {
+func NewHomebrew(osid string, r runner.Runner) *Homebrew {
 	return &Homebrew{
-		name: "brew",
-		osid: osid,
+		name:    "brew",
+		osid:    osid,
+		runner:  r,
+		command: "brew",
+		casks:   runtime.GOOS == "darwin",
 	}
 }
 
-func (h *Homebrew) Packages() packagemap {
-	universalPackages := GenerateUniversalPackages()
-
-	macSpecificPackages := packagemap{
-		"openjdk": {
-			{
-				Name:          "openjdk",
-				SystemPackage: true,
-				NativePackageName: map[string]string{
-					"brew": "openjdk@17",
-				},
-			},
-		},
-		"vscode": {
-			{
-				Name:          "vscode",
-				SystemPackage: true,
-				NativePackageName: map[string]string{
-					"brew": "visual-studio-code",
-				},
-			},
-		},
-	}
-
-	for key, value := range macSpecificPackages {
-		universalPackages[key] = value
-	}
-
-	return universalPackages
+// InstallCommand is not elevated, Homebrew refuses to run as root.
+func (h *Homebrew) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name: h.command,
+		Args: append([]string{"install"}, nativeNames(pkgs, h.name)...),
+	}
 }
 
-func (h *Homebrew) InstallCommand(pkg *Package) string {
-	packageName := pkg.NativePackageName[h.name]
+func (h *Homebrew) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name: h.command,
+		Args: append([]string{"uninstall"}, nativeNames(pkgs, h.name)...),
+	}
+}
 
-	if packageName == "" {
-		packageName = pkg.NativePackageName[h.osid]
+func (h *Homebrew) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name: h.command,
+		Args: append([]string{"upgrade"}, nativeNames(pkgs, h.name)...),
 	}
+}
 
-	return fmt.Sprintf("brew install %s", packageName)
+func (h *Homebrew) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, h.name)
 }
 
 func (h *Homebrew) Name() string {
 	return h.name
 }
 
-func (h *Homebrew) PackageInstalled(pkg *Package) (bool, error) {
-	if !pkg.SystemPackage {
-		return false, nil
+func (h *Homebrew) VersionScheme() version.Scheme {
+	return version.Generic
+}
+
+type brewInfo struct {
+	Formulae []struct {
+		Name     string   `json:"name"`
+		FullName string   `json:"full_name"`
+		Aliases  []string `json:"aliases"`
+		Versions struct {
+			Stable string `json:"stable"`
+		} `json:"versions"`
+	} `json:"formulae"`
+	Casks []struct {
+		Token   string `json:"token"`
+		Version string `json:"version"`
+	} `json:"casks"`
+}
+
+func (h *Homebrew) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, h.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, h.name, nil, nil), nil
 	}
 
-	packageName := pkg.NativePackageName[h.name]
-	if packageName == "" {
-		return false, fmt.Errorf("no Homebrew package name found for %s", pkg.Name)
+	// brew list exits with 1 if any of the names is not installed, but
+	// still prints the versions of the others.
+	stdout, _, err := runner.Output(ctx, h.runner, runner.Command{
+		Name: h.command,
+		Args: append([]string{"list", "--versions"}, names...),
+	})
+	if err != nil && !runner.IsExitError(err) {
+		return nil, err
 	}
+	installed := h.parseListVersions(string(stdout))
 
-	cmd := exec.Command("brew", "list")
-	output, err := cmd.Output()
-	if err != nil {
-		return false, err
+	available := map[string]string{}
+	if err := h.queryInfo(ctx, names, available); err != nil {
+		return nil, err
 	}
 
-	return strings.Contains(string(output), packageName), nil
+	return collectStatuses(pkgs, h.name, installed, available), nil
+}
+
+func (h *Homebrew) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, h, pkg)
+	return status.Installed, err
 }
 
-func (h *Homebrew) PackageAvailable(pkg *Package) (bool, error) {
-	if !pkg.SystemPackage {
-		return false, nil
+func (h *Homebrew) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, h, pkg)
+	return status.Available, err
+}
+
+// parseListVersions reads the lines of brew list --versions, which hold the
+// name followed by all installed versions, the newest last.
+func (h *Homebrew) parseListVersions(output string) map[string]string {
+	installed := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			installed[fields[0]] = fields[len(fields)-1]
+		}
 	}
+	return installed
+}
 
-	packageName := pkg.NativePackageName[h.name]
-	if packageName == "" {
-		return false, fmt.Errorf("no Homebrew package name found for %s", pkg.Name)
+// queryInfo looks up the stable versions of formulae and casks at once. brew
+// fails the whole query if a single name is unknown, so in that case every
+// name is looked up on its own.
+func (h *Homebrew) queryInfo(ctx context.Context, names []string, available map[string]string) error {
+	if len(names) == 0 {
+		return nil
 	}
 
-	cmd := exec.Command("brew", "search", packageName)
-	output, err := cmd.Output()
+	output, _, err := runner.Output(ctx, h.runner, runner.Command{
+		Name: h.command,
+		Args: append([]string{"info", "--json=v2"}, names...),
+	})
+	if runner.IsExitError(err) && len(names) > 1 {
+		for _, name := range names {
+			if err := h.queryInfo(ctx, []string{name}, available); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if runner.IsExitError(err) {
+		return nil
+	}
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	reg := regexp.MustCompile(packageName + `\s+(\d+[\.\d+]*)`)
-	matches := reg.FindStringSubmatch(string(output))
+	var info brewInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return fmt.Errorf("invalid brew output: %v", err)
+	}
 
-	if len(matches) > 1 {
-		pkg.Version = matches[1]
-		return true, nil
+	requested := map[string]bool{}
+	for _, name := range names {
+		requested[name] = true
 	}
 
-	return false, nil
+	for _, formula := range info.Formulae {
+		for _, name := range append([]string{formula.Name, formula.FullName}, formula.Aliases...) {
+			if !requested[name] {
+				continue
+			}
+			available[name] = formula.Versions.Stable
+		}
+	}
+	for _, cask := range info.Casks {
+		if h.casks && requested[cask.Token] {
+			available[cask.Token] = cask.Version
+		}
+	}
+	return nil
 }
 
-func (h *Homebrew) EnsureInstalled() error {
-	cmd := exec.Command("brew", "--version")
-	if err := cmd.Run(); err == nil {
+func (h *Homebrew) EnsureInstalled(ctx context.Context) error {
+	_, _, err := runner.Output(ctx, h.runner, runner.Command{
+		Name: h.command,
+		Args: []string{"--version"},
+	})
+	if err == nil {
 		return nil
 	}
 
-	cmd = exec.Command("/bin/bash", "-c", "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)")
-	err := cmd.Run()
+	_, _, err = runner.Output(ctx, h.runner, runner.Command{
+		Name: "/bin/bash",
+		Args: []string{"-c", "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)"},
+	})
 	if err != nil {
 		return fmt.Errorf("failed to install Homebrew: %v", err)
 	}
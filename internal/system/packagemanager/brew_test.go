@@ -0,0 +1,33 @@
+//go:build darwin || linux
+
+package packagemanager
+
+import "testing"
+
+func TestHomebrewQueryPackages(t *testing.T) {
+	fake := loadFixture(t, "brew/macos-14.json")
+	brew := NewHomebrew("macos", fake)
+	brew.casks = true
+	checkQuery(t, brew, fake, []queryTest{
+		{"openjdk", "openjdk@17", PackageStatus{true, true, "17.0.13", "17.0.13"}},
+		{"vscode", "visual-studio-code", PackageStatus{true, false, "1.95.3", "1.95.3"}},
+		{"podman", "podmann", PackageStatus{false, false, "", ""}},
+	}, 5)
+}
+
+func TestHomebrewLinux(t *testing.T) {
+	fake := loadFixture(t, "brew/linuxbrew.json")
+	brew := NewHomebrew("ubuntu", fake)
+	brew.command = "/home/linuxbrew/.linuxbrew/bin/brew"
+	brew.casks = false
+	checkQuery(t, brew, fake, []queryTest{
+		{"git", "git", PackageStatus{true, true, "2.47.1", "2.47.1"}},
+		{"openjdk", "openjdk@17", PackageStatus{true, false, "17.0.13", "17.0.13"}},
+		{"vscode", "visual-studio-code", PackageStatus{false, false, "", ""}},
+	}, 2)
+
+	pkg := testPackage("git", "brew", "git")
+	if got, want := brew.InstallCommand(pkg).String(), "/home/linuxbrew/.linuxbrew/bin/brew install git"; got != want {
+		t.Errorf("InstallCommand() = %q, want %q", got, want)
+	}
+}
@@ -4,129 +4,133 @@
 package packagemanager
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
-	"regexp"
 	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
 )
 
 type Chocolatey struct {
-	name string
-	osid string
+	name   string
+	osid   string
+	runner runner.Runner
 }
 
-func NewChocolatey(osid string) *Chocolatey {
+func NewChocolatey(osid string, r runner.Runner) *Chocolatey {
 	return &Chocolatey{
-		name: "choco",
-		osid: osid,
+		name:   "choco",
+		osid:   osid,
+		runner: r,
 	}
 }
 
-func (c *Chocolatey) Packages() packagemap {
-	universalPackages := GenerateUniversalPackages()
-	
-	// Add Windows-specific package modifications if needed
-	windowsSpecificPackages := packagemap{
-		"openjdk": {
-			{
-				Name:          "openjdk",
-				SystemPackage: true,
-				NativePackageName: map[string]string{
-					"choco": "openjdk17",
-				},
-			},
-		},
-		"vscode": {
-			{
-				Name:          "vscode",
-				SystemPackage: false,
-				NativePackageName: map[string]string{
-					"choco": "vscode",
-				},
-			},
-		},
+// InstallCommand is not elevated, Chocolatey has to be run from an
+// administrator shell.
+func (c *Chocolatey) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name: "choco",
+		Args: append([]string{"install", "-y"}, nativeNames(pkgs, c.name)...),
 	}
+}
 
-	// Merge Windows-specific packages with universal packages
-	for key, value := range windowsSpecificPackages {
-		universalPackages[key] = value
+func (c *Chocolatey) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name: "choco",
+		Args: append([]string{"uninstall", "-y"}, nativeNames(pkgs, c.name)...),
 	}
-
-	return universalPackages
 }
 
-func (c *Chocolatey) InstallCommand(pkg *Package) string {
-	// Use the package name specific to Chocolatey
-	packageName := pkg.NativePackageName[c.name]
-	
-	// If no Chocolatey-specific name is found, fallback to the default
-	if packageName == "" {
-		packageName = pkg.NativePackageName[c.osid]
+func (c *Chocolatey) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name: "choco",
+		Args: append([]string{"upgrade", "-y"}, nativeNames(pkgs, c.name)...),
 	}
+}
 
-	return fmt.Sprintf("choco install %s -y", packageName)
+func (c *Chocolatey) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, c.name)
 }
 
 func (c *Chocolatey) Name() string {
 	return c.name
 }
 
-func (c *Chocolatey) PackageInstalled(pkg *Package) (bool, error) {
-	// Skip non-system packages
-	if !pkg.SystemPackage {
-		return false, nil
-	}
+func (c *Chocolatey) VersionScheme() version.Scheme {
+	return version.Generic
+}
 
-	// Get the package name for Chocolatey
-	packageName := pkg.NativePackageName[c.name]
-	if packageName == "" {
-		return false, fmt.Errorf("no Chocolatey package name found for %s", pkg.Name)
+func (c *Chocolatey) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, c.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, c.name, nil, nil), nil
 	}
 
-	// Run choco list to check if package is installed
-	cmd := exec.Command("choco", "list", "--local-only", packageName)
-	output, err := cmd.Output()
+	// List all local packages at once. Since Chocolatey 2.0 list only
+	// shows local packages and no longer accepts --local-only.
+	output, _, err := runner.Output(ctx, c.runner, runner.Command{
+		Name: "choco",
+		Args: []string{"list", "--limit-output"},
+	})
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-
-	// Check if the output contains the package name
-	return strings.Contains(string(output), packageName), nil
-}
-
-func (c *Chocolatey) PackageAvailable(pkg *Package) (bool, error) {
-	// Skip non-system packages
-	if !pkg.SystemPackage {
-		return false, nil
+	installed := c.parseLimitOutput(string(output))
+
+	// choco search only takes a single name, so search each package on its own
+	available := map[string]string{}
+	for _, name := range names {
+		output, _, err := runner.Output(ctx, c.runner, runner.Command{
+			Name: "choco",
+			Args: []string{"search", name, "--exact", "--limit-output"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for key, version := range c.parseLimitOutput(string(output)) {
+			available[key] = version
+		}
 	}
 
-	// Get the package name for Chocolatey
-	packageName := pkg.NativePackageName[c.name]
-	if packageName == "" {
-		return false, fmt.Errorf("no Chocolatey package name found for %s", pkg.Name)
+	// Package ids are case insensitive
+	for _, name := range names {
+		if version, ok := installed[strings.ToLower(name)]; ok {
+			installed[name] = version
+		}
+		if version, ok := available[strings.ToLower(name)]; ok {
+			available[name] = version
+		}
 	}
 
-	// Run choco search to check package availability
-	cmd := exec.Command("choco", "search", packageName)
-	output, err := cmd.Output()
-	if err != nil {
-		return false, err
-	}
+	return collectStatuses(pkgs, c.name, installed, available), nil
+}
 
-	// Use regex to find package and extract version
-	reg := regexp.MustCompile(packageName + `\s+(\d+[\.\d+]*)`)
-	matches := reg.FindStringSubmatch(string(output))
-	
-	if len(matches) > 1 {
-		pkg.Version = matches[1]
-		return true, nil
-	}
+func (c *Chocolatey) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, c, pkg)
+	return status.Installed, err
+}
 
-	return false, nil
+func (c *Chocolatey) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, c, pkg)
+	return status.Available, err
+}
+
+// parseLimitOutput reads the "id|version" lines printed with --limit-output
+func (c *Chocolatey) parseLimitOutput(output string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		id, version, found := strings.Cut(strings.TrimSpace(line), "|")
+		if found {
+			versions[strings.ToLower(id)] = version
+		}
+	}
+	return versions
 }
 
 // EnsureInstalled checks if Chocolatey is installed, and if not, attempts to install it
-func (c *Chocolatey) EnsureInstalled() error {
+func (c *Chocolatey) EnsureInstalled(ctx context.Context) error {
 	// Check if Chocolatey is already installed
 	_, err := exec.LookPath("choco")
 	if err == nil {
@@ -134,16 +138,18 @@ func (c *Chocolatey) EnsureInstalled() error {
 	}
 
 	// Attempt to install Chocolatey using PowerShell
-	cmd := exec.Command("powershell", 
-		"-NoProfile", 
-		"-ExecutionPolicy", "Bypass", 
-		"-Command",
-		"Set-ExecutionPolicy Bypass -Scope Process -Force; " +
-		"[System.Net.ServicePointManager]::SecurityProtocol = " +
-		"[System.Net.ServicePointManager]::SecurityProtocol -bor 3072; " +
-		"iex ((New-Object System.Net.WebClient).DownloadString('https://chocolatey.org/install.ps1'))")
-	
-	err = cmd.Run()
+	_, _, err = runner.Output(ctx, c.runner, runner.Command{
+		Name: "powershell",
+		Args: []string{
+			"-NoProfile",
+			"-ExecutionPolicy", "Bypass",
+			"-Command",
+			"Set-ExecutionPolicy Bypass -Scope Process -Force; " +
+				"[System.Net.ServicePointManager]::SecurityProtocol = " +
+				"[System.Net.ServicePointManager]::SecurityProtocol -bor 3072; " +
+				"iex ((New-Object System.Net.WebClient).DownloadString('https://chocolatey.org/install.ps1'))",
+		},
+	})
 	if err != nil {
 		return fmt.Errorf("failed to install Chocolatey: %v", err)
 	}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package packagemanager
+
+import "testing"
+
+func TestChocolateyQueryPackages(t *testing.T) {
+	fake := loadFixture(t, "choco/windows-11.json")
+	checkQuery(t, NewChocolatey("windows", fake), fake, []queryTest{
+		{"git", "git", PackageStatus{true, true, "2.47.1", "2.47.1"}},
+		{"openjdk", "openjdk17", PackageStatus{true, false, "17.0.2", "17.0.2"}},
+		{"podman", "podmann", PackageStatus{false, false, "", ""}},
+	}, 4)
+}
+
+// Packages without a Chocolatey name are left out of the command instead of
+// being passed as empty arguments.
+func TestChocolateyInstallCommand(t *testing.T) {
+	git := testPackage("git", "choco", "git")
+	podman := testPackage("podman", "windows", "podman")
+	if got := NewChocolatey("windows", nil).InstallCommand(git, podman).String(); got != "choco install -y git" {
+		t.Errorf("InstallCommand() = %q, want %q", got, "choco install -y git")
+	}
+}
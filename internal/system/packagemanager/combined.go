@@ -0,0 +1,169 @@
+package packagemanager
+
+import (
+	"context"
+	"sync"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+// Combined merges the native package manager with a second source, like snap,
+// so a package counts as installed when either of them provides it. Packages
+// are installed with Primary unless only Secondary has them, and removed or
+// upgraded with the source they are installed from. It is known by the name
+// of Primary. Versions of a package follow the rules of the source that
+// handles it, see Resolve.
+type Combined struct {
+	Primary   PackageManager
+	Secondary PackageManager
+	// PreferSecondary installs packages with Secondary whenever it has them,
+	// for example when Primary needs root privileges that are not available.
+	PreferSecondary bool
+
+	// secondary holds the packages the last query resolved to Secondary.
+	secondary map[*Package]bool
+	mu        sync.Mutex
+}
+
+func NewCombined(primary PackageManager, secondary PackageManager) *Combined {
+	return &Combined{
+		Primary:   primary,
+		Secondary: secondary,
+		secondary: map[*Package]bool{},
+	}
+}
+
+func (c *Combined) Name() string {
+	return c.Primary.Name()
+}
+
+// VersionScheme returns the rules of Primary. The versions of a package are
+// compared with the scheme of Resolve(c, pkg) instead.
+func (c *Combined) VersionScheme() version.Scheme {
+	return c.Primary.VersionScheme()
+}
+
+// Backend returns the source that handles pkgs. Batches never mixes packages
+// of both sources.
+func (c *Combined) Backend(pkgs ...*Package) PackageManager {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(pkgs) > 0 && c.secondary[pkgs[0]] {
+		return c.Secondary
+	}
+	return c.Primary
+}
+
+// QueryPackages asks both sources and reports the status of the one that
+// handles the package: the one it is installed from, or Primary if it can
+// install it or knows the repository it comes from, unless PreferSecondary
+// is set.
+func (c *Combined) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	primary, err := c.Primary.QueryPackages(ctx, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := c.Secondary.QueryPackages(ctx, pkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := map[*Package]PackageStatus{}
+	for _, pkg := range pkgs {
+		p, s := primary[pkg], secondary[pkg]
+		primaryHandles := p.Installed || p.Available || pkg.Repositories[c.Primary.Name()] != nil
+		useSecondary := s.Installed && !p.Installed || s.Available && !primaryHandles
+		if c.PreferSecondary {
+			useSecondary = s.Installed || s.Available && !p.Installed
+		}
+
+		c.secondary[pkg] = useSecondary
+		statuses[pkg] = p
+		if useSecondary {
+			statuses[pkg] = s
+		}
+		pkg.Version = statuses[pkg].Version
+	}
+	return statuses, nil
+}
+
+func (c *Combined) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, c, pkg)
+	return status.Installed, err
+}
+
+func (c *Combined) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, c, pkg)
+	return status.Available, err
+}
+
+func (c *Combined) InstallCommand(pkgs ...*Package) runner.Command {
+	return c.Backend(pkgs...).InstallCommand(pkgs...)
+}
+
+func (c *Combined) RemoveCommand(pkgs ...*Package) runner.Command {
+	return c.Backend(pkgs...).RemoveCommand(pkgs...)
+}
+
+func (c *Combined) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return c.Backend(pkgs...).UpgradeCommand(pkgs...)
+}
+
+func (c *Combined) NativeNames(pkgs ...*Package) []string {
+	return c.Backend(pkgs...).NativeNames(pkgs...)
+}
+
+// Batches separates the packages of both sources and splits them further if
+// the source needs it.
+func (c *Combined) Batches(pkgs []*Package) [][]*Package {
+	var primary, secondary []*Package
+	c.mu.Lock()
+	for _, pkg := range pkgs {
+		if c.secondary[pkg] {
+			secondary = append(secondary, pkg)
+		} else {
+			primary = append(primary, pkg)
+		}
+	}
+	c.mu.Unlock()
+
+	var batches [][]*Package
+	for _, source := range []struct {
+		manager PackageManager
+		pkgs    []*Package
+	}{{c.Primary, primary}, {c.Secondary, secondary}} {
+		if len(source.pkgs) == 0 {
+			continue
+		}
+		if batcher, ok := source.manager.(Batcher); ok {
+			batches = append(batches, batcher.Batches(source.pkgs)...)
+		} else {
+			batches = append(batches, source.pkgs)
+		}
+	}
+	return batches
+}
+
+// AddRepository adds repositories of Primary, Secondary does not have any.
+func (c *Combined) AddRepository(repo *Repository) *RepositorySetup {
+	if rm, ok := c.Primary.(RepositoryManager); ok {
+		return rm.AddRepository(repo)
+	}
+	return &RepositorySetup{Repository: repo}
+}
+
+func (c *Combined) RemoveRepository(repo *Repository) *RepositorySetup {
+	if rm, ok := c.Primary.(RepositoryManager); ok {
+		return rm.RemoveRepository(repo)
+	}
+	return &RepositorySetup{Repository: repo}
+}
+
+func (c *Combined) RepositoryConfigured(repo *Repository) bool {
+	rm, ok := c.Primary.(RepositoryManager)
+	return ok && rm.RepositoryConfigured(repo)
+}
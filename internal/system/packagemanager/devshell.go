@@ -0,0 +1,48 @@
+package packagemanager
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// nixAttribute matches the attribute paths that are passed on to nix.
+var nixAttribute = regexp.MustCompile(`^[A-Za-z0-9_+-]+(\.[A-Za-z0-9_+-]+)*$`)
+
+// devShellTemplate is a flake with a development shell for the common
+// systems. Its single parameter is the list of packages.
+const devShellTemplate = `{
+  description = "Entwicklungsumgebung";
+
+  inputs.nixpkgs.url = "github:NixOS/nixpkgs/nixos-24.11";
+
+  outputs = { self, nixpkgs }:
+    let
+      systems = [ "x86_64-linux" "aarch64-linux" "x86_64-darwin" "aarch64-darwin" ];
+      forAllSystems = f: nixpkgs.lib.genAttrs systems (system: f nixpkgs.legacyPackages.${system});
+    in
+    {
+      devShells = forAllSystems (pkgs: {
+        default = pkgs.mkShell {
+          packages = with pkgs; [
+%s          ];
+        };
+      });
+    };
+}
+`
+
+// DevShellFlake returns a flake.nix whose development shell provides pkgs, so
+// a project can use them through nix develop without installing anything
+// globally. Packages are named by their attribute paths for nix, which are
+// written into the flake as they are and so must be plain attribute paths.
+func DevShellFlake(pkgs []*Package) (string, error) {
+	var lines strings.Builder
+	for _, attribute := range nativeNames(pkgs, "nix") {
+		if !nixAttribute.MatchString(attribute) {
+			return "", fmt.Errorf("invalid nix attribute path %q", attribute)
+		}
+		fmt.Fprintf(&lines, "            %s\n", attribute)
+	}
+	return fmt.Sprintf(devShellTemplate, lines.String()), nil
+}
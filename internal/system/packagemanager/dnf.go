@@ -4,85 +4,193 @@
 package packagemanager
 
 import (
-	"os/exec"
+	"context"
+	"os"
+	"regexp"
 	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
 )
 
 type Dnf struct {
-	name string
-	osid string
+	name   string
+	osid   string
+	runner runner.Runner
 }
 
-func NewDnf(osid string) *Dnf {
+func NewDnf(osid string, r runner.Runner) *Dnf {
 	return &Dnf{
-		name: "dnf",
-		osid: osid,
+		name:   "dnf",
+		osid:   osid,
+		runner: r,
+	}
+}
+
+func (y *Dnf) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "dnf",
+		Args:    append([]string{"install", "-y", "--"}, nativeNames(pkgs, y.name)...),
+		Elevate: true,
 	}
 }
 
-func (y *Dnf) Packages() packagemap {
-	universalPackages := GenerateUniversalPackages()
-	aptSpecificPackages := packagemap{}
-	for key, value := range aptSpecificPackages {
-		universalPackages[key] = value
+func (y *Dnf) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "dnf",
+		Args:    append([]string{"remove", "-y", "--"}, nativeNames(pkgs, y.name)...),
+		Elevate: true,
+	}
+}
+
+func (y *Dnf) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "dnf",
+		Args:    append([]string{"upgrade", "-y", "--"}, nativeNames(pkgs, y.name)...),
+		Elevate: true,
+	}
+}
+
+var (
+	// dnf prints "(1/2): git-2.47.1-1.fc41.x86_64.rpm ..." for every
+	// download, then "  Installing : git-2.47.1-1.fc41.x86_64  1/2" for
+	// every step of the transaction and the same for verifying it.
+	dnfDownload    = regexp.MustCompile(`^\((\d+)/(\d+)\): `)
+	dnfTransaction = regexp.MustCompile(`^\s+(\S.*?)\s*: .*\s(\d+)/(\d+)\s*$`)
+	// dnf5 numbers downloads and the steps of the transaction like
+	// "[3/4] Installing git-0:2.47.1-1.fc41.x86_64 100% ...".
+	dnf5Step = regexp.MustCompile(`^\[\s*(\d+)/(\d+)\] (\S+)`)
+)
+
+// ReportProgress leaves the command as it is, dnf prints its progress anyway.
+func (y *Dnf) ReportProgress(cmd runner.Command) runner.Command {
+	return cmd
+}
+
+// Progress counts the downloads as the first half of the transaction, running
+// it as most of the second and verifying it as the rest.
+func (y *Dnf) Progress(line string) (float64, bool) {
+	if match := dnfDownload.FindStringSubmatch(line); match != nil {
+		return stepProgress(match[1], match[2], 0, 0.5)
 	}
+	if match := dnfTransaction.FindStringSubmatch(line); match != nil {
+		if match[1] == "Verifying" {
+			return stepProgress(match[2], match[3], 0.9, 1)
+		}
+		return stepProgress(match[2], match[3], 0.5, 0.9)
+	}
+	if match := dnf5Step.FindStringSubmatch(line); match != nil {
+		switch match[3] {
+		case "Verify", "Prepare", "Installing", "Upgrading", "Downgrading", "Reinstalling", "Removing":
+			return stepProgress(match[1], match[2], 0.5, 1)
+		}
+		return stepProgress(match[1], match[2], 0, 0.5)
+	}
+	return 0, false
+}
 
-	return universalPackages
+func (y *Dnf) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, y.name)
 }
 
-func (y *Dnf) InstallCommand(pkg *Package) string {
-	if pkg.SystemPackage == false {
-		return pkg.NativePackageName[y.osid]
+func (y *Dnf) repoPath(repo *Repository) string {
+	return "/etc/yum.repos.d/" + repo.Name + ".repo"
+}
+
+func (y *Dnf) AddRepository(repo *Repository) *RepositorySetup {
+	return &RepositorySetup{
+		Repository: repo,
+		Files:      []RepositoryFile{{Path: y.repoPath(repo), Content: repoFile(repo, "")}},
+		Commands:   []runner.Command{{Name: "rpm", Args: []string{"--import", repo.KeyURL}, Elevate: true}},
 	}
-	return "dnf install " + pkg.NativePackageName[y.name] + " -y"
+}
+
+func (y *Dnf) RemoveRepository(repo *Repository) *RepositorySetup {
+	return &RepositorySetup{
+		Repository: repo,
+		Commands:   append([]runner.Command{removeFiles(y.repoPath(repo))}, rpmKeyRemoval(repo)...),
+	}
+}
+
+func (y *Dnf) RepositoryConfigured(repo *Repository) bool {
+	_, err := os.Stat(y.repoPath(repo))
+	return err == nil
 }
 
 func (y *Dnf) Name() string {
 	return y.name
 }
 
-func (y *Dnf) PackageInstalled(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
+func (y *Dnf) VersionScheme() version.Scheme {
+	return version.RPM
+}
+
+func (y *Dnf) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, y.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, y.name, nil, nil), nil
 	}
-	stdout, err := exec.Command("dnf", "info", "installed", pkg.NativePackageName[y.name]).Output()
+
+	installed, err := y.queryInstalled(ctx, names)
 	if err != nil {
-		_, ok := err.(*exec.ExitError)
-		if ok {
-			return false, nil
-		}
-		return false, err
+		return nil, err
 	}
-
-	splitoutput := strings.Split(string(stdout), "\n")
-	for _, line := range splitoutput {
-		if strings.HasPrefix(line, "Version") {
-			splitline := strings.Split(line, ":")
-			pkg.Version = strings.TrimSpace(splitline[1])
-		}
+	available, err := y.queryAvailable(ctx, names)
+	if err != nil {
+		return nil, err
 	}
 
-	return true, err
+	return collectStatuses(pkgs, y.name, installed, available), nil
 }
 
-func (y *Dnf) PackageAvailable(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
+func (y *Dnf) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, y, pkg)
+	return status.Installed, err
+}
+
+func (y *Dnf) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, y, pkg)
+	return status.Available, err
+}
+
+// queryInstalled returns the installed versions of names.
+func (y *Dnf) queryInstalled(ctx context.Context, names []string) (map[string]string, error) {
+	// rpm exits with the number of packages that are not installed.
+	stdout, _, err := runner.Output(ctx, y.runner, runner.Command{
+		Name: "rpm",
+		Args: append([]string{"-q", "--queryformat", `%{NAME} %{EVR}\n`}, names...),
+	})
+	if err != nil && !runner.IsExitError(err) {
+		return nil, err
 	}
-	stdout, err := exec.Command("dnf", "info", pkg.NativePackageName[y.name]).Output()
+	return y.parseNameVersion(string(stdout)), nil
+}
+
+// queryAvailable returns the newest versions of names in the repositories.
+func (y *Dnf) queryAvailable(ctx context.Context, names []string) (map[string]string, error) {
+	stdout, _, err := runner.Output(ctx, y.runner, runner.Command{
+		Name: "dnf",
+		Args: append([]string{"repoquery", "--quiet", "--latest-limit", "1", "--queryformat", `%{name} %{evr}\n`}, names...),
+	})
 	if err != nil {
-		_, ok := err.(*exec.ExitError)
-		if ok {
-			return false, nil
-		}
-		return false, err
+		return nil, err
 	}
-	splitoutput := strings.Split(string(stdout), "\n")
-	for _, line := range splitoutput {
-		if strings.HasPrefix(line, "Version") {
-			splitline := strings.Split(line, ":")
-			pkg.Version = strings.TrimSpace(splitline[1])
+	return y.parseNameVersion(string(stdout)), nil
+}
+
+// parseNameVersion reads "name version" lines as printed by the query formats
+// above. Other lines like "package foo is not installed" are skipped, and for
+// packages listed for several architectures the first version wins.
+func (y *Dnf) parseNameVersion(output string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if _, ok := versions[fields[0]]; !ok {
+			versions[fields[0]] = fields[1]
 		}
 	}
-	return true, nil
+	return versions
 }
@@ -0,0 +1,88 @@
+//go:build linux
+
+package packagemanager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDnfQueryPackages(t *testing.T) {
+	tests := map[string][]queryTest{
+		"dnf/fedora-41.json": {
+			{"git", "git", PackageStatus{true, true, "2.47.1-1.fc41", "2.47.1-1.fc41"}},
+			{"openjdk", "java-17-openjdk-devel", PackageStatus{true, false, "1:17.0.13.0.11-1.fc41", "1:17.0.13.0.11-1.fc41"}},
+			{"vscode", "code", PackageStatus{false, false, "", ""}},
+		},
+		"dnf/rocky-9.json": {
+			{"git", "git", PackageStatus{true, true, "2.43.5-1.el9_4", "2.43.5-1.el9_4"}},
+			{"podman", "podman", PackageStatus{true, false, "5:4.9.4-16.el9_4", "5:4.9.4-16.el9_4"}},
+		},
+	}
+
+	for fixture, queries := range tests {
+		t.Run(fixture, func(t *testing.T) {
+			fake := loadFixture(t, fixture)
+			checkQuery(t, NewDnf("fedora", fake), fake, queries, 2)
+		})
+	}
+}
+
+func TestDnfRepository(t *testing.T) {
+	repo := &Repository{
+		Name:        "vscode",
+		Description: "Visual Studio Code",
+		URL:         "https://packages.microsoft.com/yumrepos/vscode",
+		KeyURL:      "https://packages.microsoft.com/keys/microsoft.asc",
+		KeyID:       "be1229cf",
+	}
+	dnf := NewDnf("fedora", nil)
+
+	add := dnf.AddRepository(repo)
+	wantFile := RepositoryFile{Path: "/etc/yum.repos.d/vscode.repo", Content: "[vscode]\n" +
+		"name=Visual Studio Code\n" +
+		"baseurl=https://packages.microsoft.com/yumrepos/vscode\n" +
+		"enabled=1\n" +
+		"gpgcheck=1\n" +
+		"gpgkey=https://packages.microsoft.com/keys/microsoft.asc\n"}
+	if len(add.Files) != 1 || add.Files[0] != wantFile {
+		t.Errorf("AddRepository() files = %#v, want %#v", add.Files, wantFile)
+	}
+
+	var got []string
+	for _, cmd := range append(add.Commands, dnf.RemoveRepository(repo).Commands...) {
+		got = append(got, cmd.Shell())
+	}
+	want := []string{
+		"rpm --import https://packages.microsoft.com/keys/microsoft.asc",
+		"rm -f -- /etc/yum.repos.d/vscode.repo",
+		"rpm -e --allmatches gpg-pubkey-be1229cf",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestDnfProgress(t *testing.T) {
+	tests := []struct {
+		line string
+		want float64
+		ok   bool
+	}{
+		{"(1/2): git-2.47.1-1.fc41.x86_64.rpm    1.2 MB/s |  50 kB     00:00", 0.25, true},
+		{"  Running scriptlet: git-core-2.47.1-1.fc41.x86_64                  1/2 ", 0.7, true},
+		{"  Installing       : git-core-2.47.1-1.fc41.x86_64                  1/2", 0.7, true},
+		{"  Verifying        : git-2.47.1-1.fc41.x86_64                       2/2", 1, true},
+		{"[1/2] git-0:2.47.1-1.fc41.x86_64      100% |   1.2 MiB/s |  50.0 KiB |  00m00s", 0.25, true},
+		{"[4/4] Installing git-0:2.47.1-1.fc41.x86_64 100% |  10.0 MiB/s | 100.0 KiB |  00m00s", 1, true},
+		{"Installing dependencies:", 0, false},
+		{"Complete!", 0, false},
+	}
+	dnf := NewDnf("fedora", nil)
+	for _, tt := range tests {
+		got, ok := dnf.Progress(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Progress(%q) = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
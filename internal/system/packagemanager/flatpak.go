@@ -0,0 +1,282 @@
+//go:build linux
+// +build linux
+
+package packagemanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+// Flatpak installs desktop applications from a Flatpak remote, either into the
+// installation of the current user or into the system installation. Both
+// installations are queried, applications are removed and upgraded where
+// they are installed.
+type Flatpak struct {
+	name   string
+	osid   string
+	runner runner.Runner
+	// system selects the system installation instead of the per-user one
+	// for new applications and remotes.
+	system bool
+	remote string
+
+	// installedSystem holds the applications of the system installation as
+	// seen by the last query.
+	installedSystem map[string]bool
+	mu              sync.Mutex
+}
+
+func NewFlatpak(osid string, r runner.Runner, system bool) *Flatpak {
+	return &Flatpak{
+		name:   "flatpak",
+		osid:   osid,
+		runner: r,
+		system: system,
+		remote: "flathub",
+
+		installedSystem: map[string]bool{},
+	}
+}
+
+// SetSystem selects the installation new applications go to.
+func (f *Flatpak) SetSystem(system bool) {
+	f.system = system
+}
+
+func installation(system bool) string {
+	if system {
+		return "--system"
+	}
+	return "--user"
+}
+
+func (f *Flatpak) installation() string {
+	return installation(f.system)
+}
+
+// command builds a flatpak invocation for an installation. Changes to the
+// system installation need root privileges, the per-user installation does
+// not.
+func (f *Flatpak) command(operation string, system bool, args ...string) runner.Command {
+	return runner.Command{
+		Name:    "flatpak",
+		Args:    append([]string{operation, installation(system), "--noninteractive", "-y"}, args...),
+		Elevate: system,
+	}
+}
+
+func (f *Flatpak) InstallCommand(pkgs ...*Package) runner.Command {
+	return f.command("install", f.system, append([]string{f.remote}, nativeNames(pkgs, f.name)...)...)
+}
+
+// RemoveCommand and UpgradeCommand work on the installation the applications
+// are in, Batches keeps the installations apart.
+func (f *Flatpak) RemoveCommand(pkgs ...*Package) runner.Command {
+	return f.command("uninstall", f.installedIn(pkgs), nativeNames(pkgs, f.name)...)
+}
+
+func (f *Flatpak) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return f.command("update", f.installedIn(pkgs), nativeNames(pkgs, f.name)...)
+}
+
+// installedIn reports whether pkgs are in the system installation, judged by
+// the first of them. Applications that were not seen installed are assumed
+// in the installation new ones go to.
+func (f *Flatpak) installedIn(pkgs []*Package) bool {
+	names := nativeNames(pkgs, f.name)
+	if len(names) == 0 {
+		return f.system
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	system, ok := f.installedSystem[names[0]]
+	if !ok {
+		return f.system
+	}
+	return system
+}
+
+// Batches separates the applications of the user installation from those of
+// the system installation.
+func (f *Flatpak) Batches(pkgs []*Package) [][]*Package {
+	var user, system []*Package
+	for _, pkg := range pkgs {
+		if f.installedIn([]*Package{pkg}) {
+			system = append(system, pkg)
+		} else {
+			user = append(user, pkg)
+		}
+	}
+
+	var batches [][]*Package
+	for _, batch := range [][]*Package{user, system} {
+		if len(batch) > 0 {
+			batches = append(batches, batch)
+		}
+	}
+	return batches
+}
+
+func (f *Flatpak) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, f.name)
+}
+
+// AddRepository adds the Flatpak remote repo describes, URL points to its
+// .flatpakrepo file.
+func (f *Flatpak) AddRepository(repo *Repository) *RepositorySetup {
+	return &RepositorySetup{
+		Repository: repo,
+		Commands: []runner.Command{{
+			Name:    "flatpak",
+			Args:    []string{"remote-add", f.installation(), "--if-not-exists", repo.Name, repo.URL},
+			Elevate: f.system,
+		}},
+	}
+}
+
+func (f *Flatpak) RemoveRepository(repo *Repository) *RepositorySetup {
+	return &RepositorySetup{
+		Repository: repo,
+		Commands: []runner.Command{{
+			Name:    "flatpak",
+			Args:    []string{"remote-delete", f.installation(), repo.Name},
+			Elevate: f.system,
+		}},
+	}
+}
+
+// RepositoryConfigured looks for the remote in the configuration of the
+// installation.
+func (f *Flatpak) RepositoryConfigured(repo *Repository) bool {
+	config := "/var/lib/flatpak/repo/config"
+	if !f.system {
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return false
+			}
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		config = filepath.Join(dataHome, "flatpak", "repo", "config")
+	}
+
+	data, err := os.ReadFile(config)
+	return err == nil && strings.Contains(string(data), `[remote "`+repo.Name+`"]`)
+}
+
+func (f *Flatpak) Name() string {
+	return f.name
+}
+
+func (f *Flatpak) VersionScheme() version.Scheme {
+	return version.Generic
+}
+
+// QueryPackages asks flatpak info for the installed version of every
+// application, in the installation new applications go to first and then in
+// the other one, and lists the applications of the remote once per
+// installation that is needed. Installed applications are matched against the
+// remote of their own installation, the others only against the remote of the
+// installation they would go to, so a remote that only the other installation
+// has does not hide that it still has to be added.
+func (f *Flatpak) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, f.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, f.name, nil, nil), nil
+	}
+	installations := []bool{f.system, !f.system}
+
+	installed := map[string]string{}
+	installedSystem := map[string]bool{}
+	for _, name := range names {
+		for _, system := range installations {
+			stdout, _, err := runner.Output(ctx, f.runner, runner.Command{
+				Name: "flatpak",
+				Args: []string{"info", installation(system), name},
+			})
+			if runner.IsExitError(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			installed[name] = parseInfoVersion(string(stdout))
+			installedSystem[name] = system
+			break
+		}
+	}
+
+	// A remote the installation does not have lists nothing.
+	remotes := map[bool]map[string]string{}
+	available := map[string]string{}
+	for _, name := range names {
+		system, ok := installedSystem[name]
+		if !ok {
+			system = f.system
+		}
+		remote, listed := remotes[system]
+		if !listed {
+			stdout, _, err := runner.Output(ctx, f.runner, runner.Command{
+				Name: "flatpak",
+				Args: []string{"remote-ls", installation(system), "--app", "--columns=application,version", f.remote},
+			})
+			if err != nil && !runner.IsExitError(err) {
+				return nil, err
+			}
+			if err == nil {
+				remote = parseColumns(string(stdout))
+			}
+			remotes[system] = remote
+		}
+		if version, ok := remote[name]; ok {
+			available[name] = version
+		}
+	}
+
+	f.mu.Lock()
+	f.installedSystem = installedSystem
+	f.mu.Unlock()
+	return collectStatuses(pkgs, f.name, installed, available), nil
+}
+
+func (f *Flatpak) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, f, pkg)
+	return status.Installed, err
+}
+
+func (f *Flatpak) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, f, pkg)
+	return status.Available, err
+}
+
+// parseColumns parses tab separated application and version columns.
+func parseColumns(output string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		application, version, _ := strings.Cut(line, "\t")
+		if application = strings.TrimSpace(application); application != "" {
+			versions[application] = strings.TrimSpace(version)
+		}
+	}
+	return versions
+}
+
+// parseInfoVersion returns the Version field of flatpak info, which is empty
+// for applications that do not declare one.
+func parseInfoVersion(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if found && key == "Version" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
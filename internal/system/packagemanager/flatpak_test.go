@@ -0,0 +1,112 @@
+//go:build linux
+
+package packagemanager
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+func TestFlatpakQueryPackages(t *testing.T) {
+	tests := map[string][]queryTest{
+		"flatpak/fedora-41.json": {
+			{"vscode", "com.visualstudio.code", PackageStatus{true, true, "1.95.3", "1.96.2"}},
+			{"intellij", "com.jetbrains.IntelliJ-IDEA-Community", PackageStatus{true, false, "2024.3.1.1", "2024.3.1.1"}},
+		},
+		"flatpak/no-remote.json": {
+			{"vscode", "com.visualstudio.code", PackageStatus{false, false, "", ""}},
+		},
+		"flatpak/system-installation.json": {
+			{"vscode", "com.visualstudio.code", PackageStatus{true, true, "1.96.2", "1.96.2"}},
+			// Only the system installation has the remote, new
+			// applications go to the user installation.
+			{"intellij", "com.jetbrains.IntelliJ-IDEA-Community", PackageStatus{false, false, "", ""}},
+		},
+		"flatpak/system-remote.json": {
+			{"vscode", "com.visualstudio.code", PackageStatus{false, false, "", ""}},
+		},
+	}
+
+	for fixture, queries := range tests {
+		t.Run(fixture, func(t *testing.T) {
+			fake := loadFixture(t, fixture)
+			checkQuery(t, NewFlatpak("fedora", fake, false), fake, queries, 2+2*len(queries))
+		})
+	}
+}
+
+// Applications of the system installation are removed and upgraded there,
+// new ones still go to the installation of the user.
+func TestFlatpakSystemInstallation(t *testing.T) {
+	fake := loadFixture(t, "flatpak/system-installation.json")
+	flatpak := NewFlatpak("fedora", fake, false)
+	code := testPackage("vscode", "flatpak", "com.visualstudio.code")
+	intellij := testPackage("intellij", "flatpak", "com.jetbrains.IntelliJ-IDEA-Community")
+	if _, err := flatpak.QueryPackages(context.Background(), []*Package{code, intellij}); err != nil {
+		t.Fatal(err)
+	}
+
+	batches := flatpak.Batches([]*Package{code, intellij})
+	if len(batches) != 2 || batches[0][0] != intellij || batches[1][0] != code {
+		t.Fatalf("Batches() = %v, want user and system installation apart", batches)
+	}
+	if got := flatpak.UpgradeCommand(code); got.Shell() != "flatpak update --system --noninteractive -y com.visualstudio.code" || !got.Elevate {
+		t.Errorf("UpgradeCommand() = %#v", got)
+	}
+	if got := flatpak.InstallCommand(intellij); got.Shell() != "flatpak install --user --noninteractive -y flathub com.jetbrains.IntelliJ-IDEA-Community" || got.Elevate {
+		t.Errorf("InstallCommand() = %#v", got)
+	}
+
+	flatpak.SetSystem(true)
+	if got := flatpak.InstallCommand(intellij); got.Shell() != "flatpak install --system --noninteractive -y flathub com.jetbrains.IntelliJ-IDEA-Community" || !got.Elevate {
+		t.Errorf("InstallCommand() with the system installation = %#v", got)
+	}
+}
+
+// A remote of the system installation does not make an application available
+// to the user installation, the remote is added there instead.
+func TestFlatpakSystemRemote(t *testing.T) {
+	fake := loadFixture(t, "flatpak/system-remote.json")
+	flatpak := NewFlatpak("fedora", fake, false)
+	code := testPackage("vscode", "flatpak", "com.visualstudio.code")
+	statuses, err := flatpak.QueryPackages(context.Background(), []*Package{code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[code].Available {
+		t.Errorf("status = %+v, want not available", statuses[code])
+	}
+
+	repo := &Repository{Name: "flathub", URL: "https://dl.flathub.org/repo/flathub.flatpakrepo"}
+	setup := flatpak.AddRepository(repo)
+	want := "flatpak remote-add --user --if-not-exists flathub https://dl.flathub.org/repo/flathub.flatpakrepo"
+	if len(setup.Commands) != 1 || setup.Commands[0].Shell() != want || setup.Commands[0].Elevate {
+		t.Errorf("AddRepository() = %#v, want %q", setup.Commands, want)
+	}
+}
+
+func TestFlatpakCommands(t *testing.T) {
+	pkg := testPackage("vscode", "flatpak", "com.visualstudio.code")
+
+	user := NewFlatpak("fedora", nil, false)
+	want := runner.Command{
+		Name: "flatpak",
+		Args: []string{"install", "--user", "--noninteractive", "-y", "flathub", "com.visualstudio.code"},
+	}
+	if got := user.InstallCommand(pkg); !reflect.DeepEqual(got, want) {
+		t.Errorf("InstallCommand() = %#v, want %#v", got, want)
+	}
+
+	system := NewFlatpak("fedora", nil, true)
+	want = runner.Command{
+		Name:    "flatpak",
+		Args:    []string{"uninstall", "--system", "--noninteractive", "-y", "com.visualstudio.code"},
+		Elevate: true,
+	}
+	if got := system.RemoveCommand(pkg); !reflect.DeepEqual(got, want) {
+		t.Errorf("RemoveCommand() = %#v, want %#v", got, want)
+	}
+}
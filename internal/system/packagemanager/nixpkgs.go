@@ -4,13 +4,24 @@
 package packagemanager
 
 import (
+	"context"
 	"encoding/json"
-	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
 )
 
 type Nixpkgs struct {
-	name string
-	osid string
+	name   string
+	osid   string
+	runner runner.Runner
+
+	// pnames maps attribute paths to package names as seen by the last
+	// query. nix-env can only remove packages by name.
+	pnames map[string]string
+	mu     sync.Mutex
 }
 
 type NixPackageDetail struct {
@@ -19,105 +30,202 @@ type NixPackageDetail struct {
 	Version string
 }
 
-var available map[string]NixPackageDetail
-
-func NewNixpkgs(osid string) *Nixpkgs {
-	available = map[string]NixPackageDetail{}
-
+func NewNixpkgs(osid string, r runner.Runner) *Nixpkgs {
 	return &Nixpkgs{
-		name: "nixpkgs",
-		osid: osid,
+		name:   "nixpkgs",
+		osid:   osid,
+		runner: r,
+		pnames: map[string]string{},
 	}
 }
 
-func (n *Nixpkgs) Packages() packagemap {
-	universalPackages := GenerateUniversalPackages()
-	aptSpecificPackages := packagemap{}
-	for key, value := range aptSpecificPackages {
-		universalPackages[key] = value
+// InstallCommand installs into the profile of the current user, which is also
+// where PackageInstalled looks, so it must not be elevated.
+func (n *Nixpkgs) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name: "nix-env",
+		Args: append([]string{"-iA"}, nativeNames(pkgs, n.name)...),
 	}
+}
+
+// RemoveCommand removes pkgs from the profile of the current user. Packages of
+// the NixOS system configuration cannot be removed this way.
+func (n *Nixpkgs) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name: "nix-env",
+		Args: append([]string{"-e"}, n.packageNames(pkgs)...),
+	}
+}
+
+// UpgradeCommand installs pkgs again, which replaces the installed versions in
+// the profile.
+func (n *Nixpkgs) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return n.InstallCommand(pkgs...)
+}
 
-	return universalPackages
+func (n *Nixpkgs) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, n.name)
 }
 
-func (n *Nixpkgs) InstallCommand(pkg *Package) string {
-	if pkg.SystemPackage == false {
-		return pkg.NativePackageName[n.osid]
+// packageNames returns the package names of pkgs. Packages that were not
+// queried yet fall back to the last component of their attribute path.
+func (n *Nixpkgs) packageNames(pkgs []*Package) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var names []string
+	for _, attribute := range nativeNames(pkgs, n.name) {
+		name, ok := n.pnames[attribute]
+		if !ok {
+			name = attribute[strings.LastIndex(attribute, ".")+1:]
+		}
+		names = append(names, name)
 	}
-	return "nix-env -iA " + pkg.NativePackageName[n.name] + " --non-interactive"
+	return names
 }
 
 func (n *Nixpkgs) Name() string {
 	return n.name
 }
 
-func (n *Nixpkgs) PackageInstalled(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
+func (n *Nixpkgs) VersionScheme() version.Scheme {
+	return version.Nix
+}
+
+func (n *Nixpkgs) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, n.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, n.name, nil, nil), nil
 	}
 
-	stdout, err := exec.Command(".", "nix-env", "--json", "-qA", pkg.NativePackageName[n.name]).Output()
+	details, err := n.queryAvailable(ctx, names)
 	if err != nil {
-		return false, nil
+		return nil, err
 	}
 
-	var attributes map[string]NixPackageDetail
-	err = json.Unmarshal([]byte(stdout), &attributes)
+	stdout, _, err := runner.Output(ctx, n.runner, runner.Command{
+		Name: "nix-env",
+		Args: []string{"--json", "-q"},
+	})
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-
-	installed := false
-	for attribute, detail := range attributes {
-		if attribute == pkg.Name {
-			installed = true
-			pkg.Version = detail.Version
-		}
-		break
+	var profile map[string]NixPackageDetail
+	if err := json.Unmarshal(stdout, &profile); err != nil {
+		return nil, err
+	}
+	profileVersions := map[string]string{}
+	for _, detail := range profile {
+		profileVersions[detail.Pname] = detail.Version
 	}
 
-	detail, ok := available[pkg.Name]
-	if !installed && n.osid == "nixos" && ok {
-		cmd := "nix-store --query --requisites /run/current-system | cut -d- -f2- | sort | uniq | grep '^" + detail.Pname + "'"
-
-		if pkg.Library {
-			cmd += " | grep 'dev$'"
-		}
+	requisites, err := systemRequisites(ctx, n.runner, n.osid)
+	if err != nil {
+		return nil, err
+	}
 
-		stdout, err = exec.Command(".", "sh", "-c", cmd).Output()
-		if err != nil {
-			return false, nil
+	installed := map[string]string{}
+	available := map[string]string{}
+	for _, pkg := range pkgs {
+		name := pkg.NativePackageName[n.name]
+		detail, ok := details[name]
+		if !ok {
+			continue
 		}
-
-		if len(string(stdout)) > 0 {
-			installed = true
+		available[name] = detail.Version
+		n.mu.Lock()
+		n.pnames[name] = detail.Pname
+		n.mu.Unlock()
+
+		if version, ok := profileVersions[detail.Pname]; ok {
+			installed[name] = version
+		} else if version, ok := systemPackageVersion(requisites, detail.Pname, pkg.Library); ok {
+			installed[name] = version
 		}
 	}
 
-	return installed, nil
+	return collectStatuses(pkgs, n.name, installed, available), nil
 }
 
-func (n *Nixpkgs) PackageAvailable(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
-	}
+func (n *Nixpkgs) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, n, pkg)
+	return status.Installed, err
+}
 
-	stdout, err := exec.Command(".", "nix-env", "--json", "-qaA", pkg.NativePackageName[n.name]).Output()
+func (n *Nixpkgs) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, n, pkg)
+	return status.Available, err
+}
+
+// queryAvailable looks up all attribute paths at once. nix-env fails the whole
+// query if a single attribute is missing, so in that case every attribute is
+// looked up on its own.
+func (n *Nixpkgs) queryAvailable(ctx context.Context, attributes []string) (map[string]NixPackageDetail, error) {
+	stdout, _, err := runner.Output(ctx, n.runner, runner.Command{
+		Name: "nix-env",
+		Args: append([]string{"--json", "-qaA"}, attributes...),
+	})
+	if runner.IsExitError(err) && len(attributes) > 1 {
+		details := map[string]NixPackageDetail{}
+		for _, attribute := range attributes {
+			detail, err := n.queryAvailable(ctx, []string{attribute})
+			if err != nil {
+				return nil, err
+			}
+			for key, value := range detail {
+				details[key] = value
+			}
+		}
+		return details, nil
+	}
+	if runner.IsExitError(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return false, nil
+		return nil, err
 	}
 
-	var attributes map[string]NixPackageDetail
-	err = json.Unmarshal(stdout, &attributes)
-	if err != nil {
-		return false, err
+	var details map[string]NixPackageDetail
+	if err := json.Unmarshal(stdout, &details); err != nil {
+		return nil, err
 	}
+	return details, nil
+}
 
-	for attribute, detail := range attributes {
-		pkg.Version = detail.Version
-		available[attribute] = detail
-		break
+// systemRequisites returns the store paths of the current NixOS system
+// closure, nothing on other systems.
+func systemRequisites(ctx context.Context, r runner.Runner, osid string) (string, error) {
+	if osid != "nixos" {
+		return "", nil
 	}
+	stdout, _, err := runner.Output(ctx, r, runner.Command{
+		Name: "nix-store",
+		Args: []string{"--query", "--requisites", "/run/current-system"},
+	})
+	return string(stdout), err
+}
 
-	return len(pkg.Version) > 0, nil
+// systemPackageVersion looks for pname in the store paths of the current
+// NixOS system closure, e.g. /nix/store/<hash>-git-2.44.1, and returns the
+// version in the name of the path. The version has to follow pname, so git
+// does not match git-lfs-3.5.1.
+func systemPackageVersion(requisites string, pname string, library bool) (string, bool) {
+	if pname == "" {
+		return "", false
+	}
+	for _, storePath := range strings.Split(requisites, "\n") {
+		_, name, _ := strings.Cut(storePath, "-")
+		version, found := strings.CutPrefix(name, pname+"-")
+		if !found || version == "" || version[0] < '0' || version[0] > '9' {
+			continue
+		}
+		if library {
+			// Libraries count with their headers only.
+			if version, found = strings.CutSuffix(version, "-dev"); !found {
+				continue
+			}
+		}
+		return version, true
+	}
+	return "", false
 }
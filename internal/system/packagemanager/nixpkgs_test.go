@@ -0,0 +1,63 @@
+//go:build linux
+
+package packagemanager
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNixpkgsQueryPackages(t *testing.T) {
+	fake := loadFixture(t, "nixpkgs/nixos-24.11.json")
+	checkQuery(t, NewNixpkgs("nixos", fake), fake, []queryTest{
+		{"git", "git", PackageStatus{true, true, "2.47.0", "2.47.0"}},
+		{"maven", "maven", PackageStatus{true, true, "3.9.9", "3.9.9"}},
+		{"vscode", "vscode", PackageStatus{false, false, "", ""}},
+	}, 6)
+}
+
+func TestNixpkgsSystemPackageVersion(t *testing.T) {
+	requisites := loadFixture(t, "nixpkgs/nixos-24.11.json").
+		Responses["nix-store --query --requisites /run/current-system"].Stdout
+
+	tests := []struct {
+		pname   string
+		library bool
+		want    string
+		found   bool
+	}{
+		{"git", false, "2.47.0", true},
+		{"git-lfs", false, "3.5.1", true},
+		{"curl", false, "8.11.0", true},
+		{"glibc", false, "2.40-36", true},
+		{"maven", false, "", false},
+		{"zlib", true, "1.3.1", true},
+		{"openssl", true, "", false},
+		// The system derivation is named after the host, not a version.
+		{"nixos", false, "", false},
+		{"", false, "", false},
+	}
+
+	for _, tt := range tests {
+		if got, found := systemPackageVersion(requisites, tt.pname, tt.library); got != tt.want || found != tt.found {
+			t.Errorf("systemPackageVersion(%q, %v) = %q, %v, want %q, %v", tt.pname, tt.library, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestNixpkgsRemoveCommand(t *testing.T) {
+	fake := loadFixture(t, "nixpkgs/nixos-24.11.json")
+	n := NewNixpkgs("nixos", fake)
+	git := testPackage("git", "nixpkgs", "git")
+	jdk := testPackage("openjdk", "nixpkgs", "nixpkgs.jdk17")
+
+	if _, err := n.QueryPackages(context.Background(), []*Package{git}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := n.RemoveCommand(git, jdk)
+	if want := []string{"-e", "git", "jdk17"}; got.Name != "nix-env" || !reflect.DeepEqual(got.Args, want) || got.Elevate {
+		t.Errorf("RemoveCommand() = %#v, want nix-env %v", got, want)
+	}
+}
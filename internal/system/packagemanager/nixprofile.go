@@ -0,0 +1,255 @@
+//go:build linux
+// +build linux
+
+package packagemanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+// NixProfile installs packages of the nixpkgs flake into the profile of the
+// current user with nix profile. Native names are attribute paths below the
+// legacyPackages of the flake, like "jdk17".
+type NixProfile struct {
+	name   string
+	osid   string
+	runner runner.Runner
+	flake  string
+
+	// elements maps attribute paths to the names of the profile elements
+	// that hold them, as seen by the last query. nix profile removes and
+	// upgrades elements by name.
+	elements map[string]string
+	mu       sync.Mutex
+}
+
+// nixEvalResult describes a package of the flake, null for missing ones.
+type nixEvalResult struct {
+	Pname   string `json:"pname"`
+	Version string `json:"version"`
+}
+
+type nixProfileElement struct {
+	AttrPath   string   `json:"attrPath"`
+	StorePaths []string `json:"storePaths"`
+}
+
+func NewNixProfile(osid string, r runner.Runner) *NixProfile {
+	return &NixProfile{
+		name:     "nix",
+		osid:     osid,
+		runner:   r,
+		flake:    "nixpkgs",
+		elements: map[string]string{},
+	}
+}
+
+// command builds a nix invocation with the features nix profile and flakes
+// need, which are still experimental.
+func (p *NixProfile) command(args ...string) runner.Command {
+	return runner.Command{
+		Name: "nix",
+		Args: append([]string{"--extra-experimental-features", "nix-command flakes"}, args...),
+	}
+}
+
+// InstallCommand installs into the profile of the current user, so it must
+// not be elevated.
+func (p *NixProfile) InstallCommand(pkgs ...*Package) runner.Command {
+	var installables []string
+	for _, attribute := range nativeNames(pkgs, p.name) {
+		installables = append(installables, p.flake+"#"+attribute)
+	}
+	return p.command(append([]string{"profile", "install"}, installables...)...)
+}
+
+// RemoveCommand removes pkgs from the profile of the current user. Packages of
+// the NixOS system configuration cannot be removed this way.
+func (p *NixProfile) RemoveCommand(pkgs ...*Package) runner.Command {
+	return p.command(append([]string{"profile", "remove"}, p.elementNames(pkgs)...)...)
+}
+
+func (p *NixProfile) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return p.command(append([]string{"profile", "upgrade"}, p.elementNames(pkgs)...)...)
+}
+
+func (p *NixProfile) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, p.name)
+}
+
+// elementNames returns the profile elements of pkgs. Packages that were not
+// queried yet fall back to the last component of their attribute path, which
+// is the name nix profile gives new elements.
+func (p *NixProfile) elementNames(pkgs []*Package) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var names []string
+	for _, attribute := range nativeNames(pkgs, p.name) {
+		name, ok := p.elements[attribute]
+		if !ok {
+			name = attribute[strings.LastIndex(attribute, ".")+1:]
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func (p *NixProfile) Name() string {
+	return p.name
+}
+
+func (p *NixProfile) VersionScheme() version.Scheme {
+	return version.Nix
+}
+
+// QueryPackages evaluates the packages in the flake once, lists the profile
+// and on NixOS also looks into the system closure.
+func (p *NixProfile) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	var names []string
+	for _, name := range nativeNames(pkgs, p.name) {
+		if nixAttribute.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return collectStatuses(pkgs, p.name, nil, nil), nil
+	}
+
+	stdout, _, err := runner.Output(ctx, p.runner, p.command("eval", "--json", "--impure", "--expr", p.evalExpression(names)))
+	if err != nil {
+		return nil, err
+	}
+	var details map[string]*nixEvalResult
+	if err := json.Unmarshal(stdout, &details); err != nil {
+		return nil, fmt.Errorf("invalid nix eval output: %v", err)
+	}
+
+	stdout, _, err = runner.Output(ctx, p.runner, p.command("profile", "list", "--json"))
+	if err != nil {
+		return nil, err
+	}
+	elements, err := p.parseProfile(stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	requisites, err := systemRequisites(ctx, p.runner, p.osid)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]string{}
+	available := map[string]string{}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pkg := range pkgs {
+		attribute := pkg.NativePackageName[p.name]
+		detail := details[attribute]
+		if detail == nil {
+			continue
+		}
+		available[attribute] = detail.Version
+
+		for name, element := range elements {
+			if !element.holds(attribute) {
+				continue
+			}
+			p.elements[attribute] = name
+			installed[attribute] = element.version(detail.Pname)
+		}
+		if _, ok := installed[attribute]; ok {
+			continue
+		}
+		if version, ok := systemPackageVersion(requisites, detail.Pname, pkg.Library); ok {
+			installed[attribute] = version
+		}
+	}
+
+	return collectStatuses(pkgs, p.name, installed, available), nil
+}
+
+func (p *NixProfile) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, p, pkg)
+	return status.Installed, err
+}
+
+func (p *NixProfile) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, p, pkg)
+	return status.Available, err
+}
+
+// evalExpression looks up all attributes in the flake for the current system
+// at once, missing attributes evaluate to null.
+func (p *NixProfile) evalExpression(attributes []string) string {
+	quoted := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		quoted[i] = strconv.Quote(attribute)
+	}
+	return fmt.Sprintf(`let pkgs = (builtins.getFlake %q).legacyPackages.${builtins.currentSystem}; `+
+		`find = attr: pkgs.lib.attrByPath (pkgs.lib.splitString "." attr) null pkgs; `+
+		`describe = pkg: if pkg == null then null else { pname = pkg.pname or ""; version = pkg.version or ""; }; `+
+		`in builtins.listToAttrs (map (attr: { name = attr; value = describe (find attr); }) [ %s ])`,
+		p.flake, strings.Join(quoted, " "))
+}
+
+// parseProfile reads nix profile list --json. Since version 3 the elements
+// are keyed by name, before they were a list addressed by index.
+func (p *NixProfile) parseProfile(output []byte) (map[string]nixProfileElement, error) {
+	var profile struct {
+		Elements json.RawMessage `json:"elements"`
+	}
+	if err := json.Unmarshal(output, &profile); err != nil {
+		return nil, fmt.Errorf("invalid nix profile output: %v", err)
+	}
+
+	elements := map[string]nixProfileElement{}
+	if len(profile.Elements) == 0 {
+		return elements, nil
+	}
+	if err := json.Unmarshal(profile.Elements, &elements); err == nil {
+		return elements, nil
+	}
+	var list []nixProfileElement
+	if err := json.Unmarshal(profile.Elements, &list); err != nil {
+		return nil, fmt.Errorf("invalid nix profile output: %v", err)
+	}
+	for i, element := range list {
+		elements[strconv.Itoa(i)] = element
+	}
+	return elements, nil
+}
+
+// holds reports whether the element was installed from attribute, its
+// attribute path is like "legacyPackages.x86_64-linux.git".
+func (e nixProfileElement) holds(attribute string) bool {
+	parts := strings.SplitN(e.AttrPath, ".", 3)
+	return len(parts) == 3 && parts[2] == attribute
+}
+
+// version returns the version in the name of the store path of the element,
+// like /nix/store/<hash>-git-2.47.0.
+func (e nixProfileElement) version(pname string) string {
+	if len(e.StorePaths) == 0 {
+		return ""
+	}
+	_, name, _ := strings.Cut(path.Base(e.StorePaths[0]), "-")
+	if pname != "" && strings.HasPrefix(name, pname+"-") {
+		return strings.TrimPrefix(name, pname+"-")
+	}
+	for i := 0; i < len(name)-1; i++ {
+		if name[i] == '-' && name[i+1] >= '0' && name[i+1] <= '9' {
+			return name[i+1:]
+		}
+	}
+	return ""
+}
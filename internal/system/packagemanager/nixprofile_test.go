@@ -0,0 +1,53 @@
+//go:build linux
+
+package packagemanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNixProfileQueryPackages(t *testing.T) {
+	fake := loadFixture(t, "nix/nixos-24.11.json")
+	checkQuery(t, NewNixProfile("nixos", fake), fake, []queryTest{
+		// git is part of the system configuration, in an older version
+		// than the channel has.
+		{"git", "git", PackageStatus{true, true, "2.46.1", "2.47.0"}},
+		{"openjdk", "jdk17", PackageStatus{true, true, "17.0.13+11", "17.0.13+11"}},
+		{"maven", "maven", PackageStatus{true, false, "3.9.9", "3.9.9"}},
+		{"podman", "podmann", PackageStatus{false, false, "", ""}},
+	}, 3)
+}
+
+func TestNixProfileParseProfile(t *testing.T) {
+	p := NewNixProfile("nixos", nil)
+	legacy := `{"elements": [{"active": true, "attrPath": "legacyPackages.x86_64-linux.maven", "storePaths": ["/nix/store/kx8cc2a0lm2m6p4b1ffmcg0jcqr8ll2n-maven-3.9.9"]}], "version": 2}`
+
+	elements, err := p.parseProfile([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	element, ok := elements["0"]
+	if !ok || !element.holds("maven") || element.version("maven") != "3.9.9" {
+		t.Errorf("parseProfile() = %+v", elements)
+	}
+}
+
+func TestNixProfileCommands(t *testing.T) {
+	p := NewNixProfile("nixos", nil)
+	jdk := testPackage("openjdk", "nix", "jdk17")
+	p.elements["jdk17"] = "openjdk"
+
+	tests := []struct {
+		got  []string
+		want []string
+	}{
+		{p.InstallCommand(jdk).Args, []string{"--extra-experimental-features", "nix-command flakes", "profile", "install", "nixpkgs#jdk17"}},
+		{p.RemoveCommand(jdk).Args, []string{"--extra-experimental-features", "nix-command flakes", "profile", "remove", "openjdk"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("Args = %q, want %q", tt.got, tt.want)
+		}
+	}
+}
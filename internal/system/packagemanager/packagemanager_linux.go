@@ -2,7 +2,15 @@
 
 package packagemanager
 
-import "os/exec"
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/operatingsystem"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
 
 var pmcommands = []string{
 	"apt",
@@ -10,30 +18,161 @@ var pmcommands = []string{
 	"pacman",
 	"zypper",
 	"nix-env",
+	"apk",
+}
+
+// usercommands are package managers that install into a prefix of their own
+// next to the one of the distribution.
+var usercommands = []string{
+	"brew",
+}
+
+// desktopcommands are backends for applications that complement the package
+// manager of the distribution.
+var desktopcommands = []string{
+	"snap",
+	"flatpak",
+}
+
+// nativeCommands maps OS IDs to the package manager of the distribution.
+// Derived distributions are found through the ones they are like.
+var nativeCommands = map[string]string{
+	"debian":              "apt",
+	"ubuntu":              "apt",
+	"linuxmint":           "apt",
+	"pop":                 "apt",
+	"fedora":              "dnf",
+	"rhel":                "dnf",
+	"centos":              "dnf",
+	"rocky":               "dnf",
+	"almalinux":           "dnf",
+	"arch":                "pacman",
+	"manjaro":             "pacman",
+	"endeavouros":         "pacman",
+	"opensuse-leap":       "zypper",
+	"opensuse-tumbleweed": "zypper",
+	"sles":                "zypper",
+	"opensuse":            "zypper",
+	"suse":                "zypper",
+	"nixos":               "nix-env",
+	"alpine":              "apk",
 }
 
-func Find(osid string) PackageManager {
-	for _, pmname := range pmcommands {
+// Find returns all package managers found on the system, ranked by how well
+// they suit the distribution.
+func Find(info *operatingsystem.OS, r runner.Runner) []PackageManager {
+	osid := info.ID
+	var managers []PackageManager
+	for _, pmname := range rank(info) {
+		if pmname == "brew" {
+			if command := findBrew(); command != "" {
+				brew := NewHomebrew(osid, r)
+				brew.command = command
+				managers = append(managers, brew)
+			}
+			continue
+		}
+
 		_, err := exec.LookPath(pmname)
 		if err == nil {
-			return newPackageManager(pmname, osid)
+			managers = append(managers, newPackageManager(pmname, osid, r))
 		}
 	}
-	return nil
+	return managers
 }
 
-func newPackageManager(pmname string, osid string) PackageManager {
+// findBrew returns the command to run Homebrew with. Homebrew on Linux is
+// often not on the PATH, so its default prefixes are searched as well.
+func findBrew() string {
+	if _, err := exec.LookPath("brew"); err == nil {
+		return "brew"
+	}
+
+	prefixes := []string{"/home/linuxbrew/.linuxbrew"}
+	if home, err := os.UserHomeDir(); err == nil {
+		prefixes = append(prefixes, filepath.Join(home, ".linuxbrew"))
+	}
+	for _, prefix := range prefixes {
+		command := filepath.Join(prefix, "bin", "brew")
+		if _, err := exec.LookPath(command); err == nil {
+			return command
+		}
+	}
+	return ""
+}
+
+// rank orders the commands of all backends: the package manager of the
+// distribution first, then the other package managers, Homebrew and desktop
+// backends last. On ostree based systems dnf cannot install into the image,
+// rpm-ostree takes its place and toolbox containers are offered instead.
+func rank(info *operatingsystem.OS) []string {
+	commands := slices.Clone(pmcommands)
+	if native, ok := nativeCommand(info); ok {
+		i := slices.Index(commands, native)
+		commands = append([]string{native}, slices.Delete(commands, i, i+1)...)
+	}
+
+	user := usercommands
+	if info.OSTree {
+		commands = slices.DeleteFunc(commands, func(command string) bool { return command == "dnf" })
+		commands = append([]string{"rpm-ostree"}, commands...)
+		user = append([]string{"toolbox"}, user...)
+	}
+	commands = append(commands, user...)
+	return append(commands, desktopcommands...)
+}
+
+// nativeCommand returns the package manager of the distribution, or of the
+// closest one it derives from.
+func nativeCommand(info *operatingsystem.OS) (string, bool) {
+	for _, id := range append([]string{info.ID}, info.IDLike...) {
+		if native, ok := nativeCommands[id]; ok {
+			return native, true
+		}
+	}
+	return "", false
+}
+
+// nixProfileUsable reports whether nix profile can manage the profile of the
+// user. Profiles that nix-env created are only left to nix-env.
+func nixProfileUsable() bool {
+	if _, err := exec.LookPath("nix"); err != nil {
+		return false
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(home, ".nix-profile", "manifest.nix"))
+	return os.IsNotExist(err)
+}
+
+func newPackageManager(pmname string, osid string, r runner.Runner) PackageManager {
 	switch pmname {
 	case "apt":
-		return NewApt(osid)
+		return NewApt(osid, r)
 	case "dnf":
-		return NewDnf(osid)
+		return NewDnf(osid, r)
 	case "pacman":
-		return NewPacman(osid)
+		return NewPacman(osid, r)
 	case "zypper":
-		return NewZypper(osid)
+		return NewZypper(osid, r)
 	case "nix-env":
-		return NewNixpkgs(osid)
+		if nixProfileUsable() {
+			return NewNixProfile(osid, r)
+		}
+		return NewNixpkgs(osid, r)
+	case "apk":
+		return NewApk(osid, r)
+	case "rpm-ostree":
+		_, err := exec.LookPath("dnf")
+		return NewRpmOstree(osid, r, err == nil)
+	case "toolbox":
+		return NewToolbox(osid, r, "")
+	case "snap":
+		return NewSnap(osid, r)
+	case "flatpak":
+		return NewFlatpak(osid, r, false)
 	}
 	return nil
 }
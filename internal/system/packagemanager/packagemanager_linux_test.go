@@ -0,0 +1,37 @@
+//go:build linux
+
+package packagemanager
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/operatingsystem"
+)
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		os   operatingsystem.OS
+		want []string
+	}{
+		{operatingsystem.OS{ID: "ubuntu", IDLike: []string{"debian"}}, []string{"apt", "dnf", "pacman", "zypper", "nix-env", "apk", "brew", "snap", "flatpak"}},
+		{operatingsystem.OS{ID: "fedora"}, []string{"dnf", "apt", "pacman", "zypper", "nix-env", "apk", "brew", "snap", "flatpak"}},
+		{operatingsystem.OS{ID: "nixos"}, []string{"nix-env", "apt", "dnf", "pacman", "zypper", "apk", "brew", "snap", "flatpak"}},
+		{operatingsystem.OS{ID: "alpine"}, []string{"apk", "apt", "dnf", "pacman", "zypper", "nix-env", "brew", "snap", "flatpak"}},
+		{operatingsystem.OS{ID: "unknown"}, []string{"apt", "dnf", "pacman", "zypper", "nix-env", "apk", "brew", "snap", "flatpak"}},
+		// Derived distributions use the package manager of the closest one
+		// they are like.
+		{operatingsystem.OS{ID: "zorin", IDLike: []string{"ubuntu", "debian"}}, []string{"apt", "dnf", "pacman", "zypper", "nix-env", "apk", "brew", "snap", "flatpak"}},
+		{operatingsystem.OS{ID: "garuda", IDLike: []string{"arch"}}, []string{"pacman", "apt", "dnf", "zypper", "nix-env", "apk", "brew", "snap", "flatpak"}},
+		{operatingsystem.OS{ID: "ol", IDLike: []string{"fedora"}}, []string{"dnf", "apt", "pacman", "zypper", "nix-env", "apk", "brew", "snap", "flatpak"}},
+		{operatingsystem.OS{ID: "opensuse-slowroll", IDLike: []string{"opensuse", "suse"}}, []string{"zypper", "apt", "dnf", "pacman", "nix-env", "apk", "brew", "snap", "flatpak"}},
+		{operatingsystem.OS{ID: "fedora", OSTree: true}, []string{"rpm-ostree", "apt", "pacman", "zypper", "nix-env", "apk", "toolbox", "brew", "snap", "flatpak"}},
+		{operatingsystem.OS{ID: "bazzite", IDLike: []string{"fedora"}, OSTree: true}, []string{"rpm-ostree", "apt", "pacman", "zypper", "nix-env", "apk", "toolbox", "brew", "snap", "flatpak"}},
+	}
+
+	for _, tt := range tests {
+		if got := rank(&tt.os); !slices.Equal(got, tt.want) {
+			t.Errorf("rank(%+v) = %v, want %v", tt.os, got, tt.want)
+		}
+	}
+}
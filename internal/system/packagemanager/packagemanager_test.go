@@ -0,0 +1,206 @@
+package packagemanager
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/operatingsystem"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+func loadFixture(t *testing.T, name string) *runner.Fake {
+	t.Helper()
+	fake, err := runner.LoadFake(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake
+}
+
+func testPackage(name string, manager string, native string) *Package {
+	return &Package{
+		Name:              name,
+		SystemPackage:     true,
+		NativePackageName: map[string]string{manager: native},
+	}
+}
+
+type queryTest struct {
+	name   string
+	native string
+	want   PackageStatus
+}
+
+// checkQuery queries all packages of tests in a single batch and fails if the
+// backend needed more than maxCalls commands for it.
+func checkQuery(t *testing.T, pm PackageManager, fake *runner.Fake, tests []queryTest, maxCalls int) {
+	t.Helper()
+
+	pkgs := make([]*Package, len(tests))
+	for i, tt := range tests {
+		pkgs[i] = testPackage(tt.name, pm.Name(), tt.native)
+	}
+
+	statuses, err := pm.QueryPackages(context.Background(), pkgs)
+	if err != nil {
+		t.Fatalf("QueryPackages() error = %v", err)
+	}
+
+	for i, tt := range tests {
+		if got := statuses[pkgs[i]]; got != tt.want {
+			t.Errorf("%s: status = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if pkgs[i].Version != tt.want.Version {
+			t.Errorf("%s: Version = %q, want %q", tt.name, pkgs[i].Version, tt.want.Version)
+		}
+	}
+
+	if len(fake.Calls) > maxCalls {
+		t.Errorf("QueryPackages() ran %d commands, want at most %d", len(fake.Calls), maxCalls)
+	}
+}
+
+func TestPackageStatusOutdated(t *testing.T) {
+	tests := []struct {
+		status PackageStatus
+		want   bool
+	}{
+		{PackageStatus{true, true, "1.95.3-1731513102", "1.96.0-1733888194"}, true},
+		{PackageStatus{true, true, "1.96.0-1733888194", "1.96.0-1733888194"}, false},
+		{PackageStatus{true, true, "1.96.0-1733888194", "1.95.3-1731513102"}, false},
+		{PackageStatus{true, true, "1:2.39.5-0+deb12u1", "2.40.0-1"}, false},
+		{PackageStatus{true, false, "1.96.0", "1.96.0"}, false},
+		{PackageStatus{true, true, "1.95.3", ""}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.Outdated(version.Debian); got != tt.want {
+			t.Errorf("%+v.Outdated() = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestDevShellFlake(t *testing.T) {
+	pkgs := []*Package{
+		testPackage("openjdk", "nix", "jdk17"),
+		testPackage("maven", "nix", "maven"),
+		testPackage("vscode", "flatpak", "com.visualstudio.code"),
+	}
+
+	flake, err := DevShellFlake(pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(flake, "            jdk17\n            maven\n          ];") {
+		t.Errorf("DevShellFlake() does not list the packages:\n%s", flake)
+	}
+	if strings.Contains(flake, "visualstudio") {
+		t.Errorf("DevShellFlake() lists a package without a name for nix:\n%s", flake)
+	}
+
+	// Attribute paths end up in the flake as they are.
+	for _, attribute := range []string{"jdk17 ]; evil = [", "maven\n", "jdk..17"} {
+		if _, err := DevShellFlake([]*Package{testPackage("openjdk", "nix", attribute)}); err == nil {
+			t.Errorf("DevShellFlake() accepted the attribute path %q", attribute)
+		}
+	}
+}
+
+func TestResolveNames(t *testing.T) {
+	newJDK := func() *Package {
+		return &Package{
+			Name:              "openjdk",
+			NativePackageName: map[string]string{"apt": "openjdk-17-jdk", "zypper": "java-17-openjdk-devel"},
+			Variants: []*Variant{
+				{Manager: "apt", OS: "debian", Versions: "< 11", Name: ""},
+				{Manager: "apt", OS: "ubuntu", Versions: ">= 24.04", Name: "openjdk-21-jdk"},
+				{Manager: "apt", OS: "debian", Versions: ">= 13", Name: "openjdk-21-jdk"},
+				{Manager: "zypper", OS: "suse", Name: "java-17-openjdk-devel"},
+				{Manager: "zypper", OS: "opensuse-tumbleweed", Name: "java-21-openjdk-devel"},
+			},
+		}
+	}
+
+	tests := []struct {
+		system  operatingsystem.OS
+		manager string
+		want    string
+	}{
+		{operatingsystem.OS{ID: "debian", Version: "12"}, "apt", "openjdk-17-jdk"},
+		{operatingsystem.OS{ID: "debian", Version: "10"}, "apt", ""},
+		{operatingsystem.OS{ID: "debian", Version: "13"}, "apt", "openjdk-21-jdk"},
+		{operatingsystem.OS{ID: "ubuntu", IDLike: []string{"debian"}, Version: "24.04"}, "apt", "openjdk-21-jdk"},
+		{operatingsystem.OS{ID: "ubuntu", IDLike: []string{"debian"}, Version: "22.04"}, "apt", "openjdk-17-jdk"},
+		{operatingsystem.OS{ID: "linuxmint", IDLike: []string{"ubuntu", "debian"}, Version: "22"}, "apt", "openjdk-17-jdk"},
+		{operatingsystem.OS{ID: "opensuse-tumbleweed", IDLike: []string{"opensuse", "suse"}, Version: "20241215"}, "zypper", "java-21-openjdk-devel"},
+		{operatingsystem.OS{ID: "opensuse-leap", IDLike: []string{"suse", "opensuse"}, Version: "15.6"}, "zypper", "java-17-openjdk-devel"},
+		{operatingsystem.OS{ID: "debian", Version: "Unknown"}, "apt", "openjdk-17-jdk"},
+	}
+
+	for _, tt := range tests {
+		pkg := newJDK()
+		if err := ResolveNames([]*Package{pkg}, &tt.system); err != nil {
+			t.Fatal(err)
+		}
+		if got := pkg.NativePackageName[tt.manager]; got != tt.want {
+			t.Errorf("%s %s: %s name = %q, want %q", tt.system.ID, tt.system.Version, tt.manager, got, tt.want)
+		}
+	}
+
+	jdk := newJDK()
+	jdk.Alternatives = []*Package{{
+		Name:              "default-jdk",
+		NativePackageName: map[string]string{"apt": "default-jdk"},
+		Variants:          []*Variant{{Manager: "apt", OS: "ubuntu", Versions: "< 24.04", Name: ""}},
+	}}
+	if err := ResolveNames([]*Package{jdk}, &operatingsystem.OS{ID: "ubuntu", IDLike: []string{"debian"}, Version: "22.04"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := jdk.Alternatives[0].NativePackageName["apt"]; got != "" {
+		t.Errorf("alternative apt name = %q, want it removed", got)
+	}
+
+	invalid := &Package{Name: "broken", Variants: []*Variant{{Manager: "apt", OS: "debian", Versions: ">=", Name: "x"}}}
+	if err := ResolveNames([]*Package{invalid}, &operatingsystem.OS{ID: "debian", Version: "12"}); err == nil {
+		t.Error("ResolveNames() with an invalid version range succeeded, want error")
+	}
+}
+
+func TestSortByRequires(t *testing.T) {
+	newPackages := func(requires map[string][]string, names ...string) []*Package {
+		var pkgs []*Package
+		for _, name := range names {
+			pkgs = append(pkgs, &Package{Name: name, Requires: requires[name]})
+		}
+		return pkgs
+	}
+
+	pkgs := newPackages(map[string][]string{
+		"maven":          {"openjdk"},
+		"gradle":         {"openjdk"},
+		"podman-compose": {"podman"},
+	}, "git", "maven", "podman-compose", "openjdk", "gradle", "podman")
+	sorted, err := SortByRequires(pkgs)
+	if err != nil {
+		t.Fatalf("SortByRequires() error = %v", err)
+	}
+	var names []string
+	for _, pkg := range sorted {
+		names = append(names, pkg.Name)
+	}
+	if got, want := strings.Join(names, " "), "git openjdk maven podman podman-compose gradle"; got != want {
+		t.Errorf("SortByRequires() = %s, want %s", got, want)
+	}
+
+	cycle := newPackages(map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}}, "a", "b", "c")
+	if _, err := SortByRequires(cycle); err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("SortByRequires() of a cycle error = %v", err)
+	}
+	unknown := newPackages(map[string][]string{"maven": {"openjdk"}}, "maven")
+	if _, err := SortByRequires(unknown); err == nil {
+		t.Error("SortByRequires() with an unknown package succeeded, want error")
+	}
+}
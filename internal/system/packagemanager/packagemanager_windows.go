@@ -5,12 +5,16 @@ package packagemanager
 
 import (
 	"os/exec"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/operatingsystem"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
 )
 
-func Find(osid string) PackageManager {
+// Find returns the package managers found on the system.
+func Find(info *operatingsystem.OS, r runner.Runner) []PackageManager {
 	_, err := exec.LookPath("choco")
 	if err == nil {
-		return NewChocolatey(osid)
+		return []PackageManager{NewChocolatey(info.ID, r)}
 	}
 
 	return nil
@@ -4,88 +4,156 @@
 package packagemanager
 
 import (
-	"os/exec"
+	"context"
 	"regexp"
 	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
 )
 
 type Pacman struct {
-	name string
-	osid string
+	name   string
+	osid   string
+	runner runner.Runner
 }
 
-func NewPacman(osid string) *Pacman {
+func NewPacman(osid string, r runner.Runner) *Pacman {
 	return &Pacman{
-		name: "pacman",
-		osid: osid,
+		name:   "pacman",
+		osid:   osid,
+		runner: r,
 	}
 }
 
-func (p *Pacman) Packages() packagemap {
-	universalPackages := GenerateUniversalPackages()
-	aptSpecificPackages := packagemap{}
-	for key, value := range aptSpecificPackages {
-		universalPackages[key] = value
+func (p *Pacman) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "pacman",
+		Args:    append([]string{"-S", "--noconfirm", "--"}, nativeNames(pkgs, p.name)...),
+		Elevate: true,
 	}
+}
 
-	return universalPackages
+func (p *Pacman) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "pacman",
+		Args:    append([]string{"-R", "--noconfirm", "--"}, nativeNames(pkgs, p.name)...),
+		Elevate: true,
+	}
 }
 
-func (p *Pacman) InstallCommand(pkg *Package) string {
-	if pkg.SystemPackage == false {
-		return pkg.NativePackageName[p.osid]
+func (p *Pacman) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "pacman",
+		Args:    append([]string{"-S", "--noconfirm", "--"}, nativeNames(pkgs, p.name)...),
+		Elevate: true,
 	}
-	return "pacman -S " + pkg.NativePackageName[p.name] + " --noconfirm"
+}
+
+// pacman numbers the packages in every step of the transaction, like
+// "(1/2) checking package integrity" and "(1/2) installing git".
+var pacmanStep = regexp.MustCompile(`^\((\d+)/(\d+)\) (\S+)`)
+
+// ReportProgress leaves the command as it is, pacman prints its progress
+// anyway.
+func (p *Pacman) ReportProgress(cmd runner.Command) runner.Command {
+	return cmd
+}
+
+// Progress counts checking and loading the downloaded packages as the first
+// half of the transaction and installing or removing them as the second.
+func (p *Pacman) Progress(line string) (float64, bool) {
+	match := pacmanStep.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false
+	}
+	switch match[3] {
+	case "installing", "upgrading", "downgrading", "reinstalling", "removing":
+		return stepProgress(match[1], match[2], 0.5, 1)
+	}
+	return stepProgress(match[1], match[2], 0, 0.5)
+}
+
+func (p *Pacman) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, p.name)
 }
 
 func (p *Pacman) Name() string {
 	return p.name
 }
 
-func (p *Pacman) PackageInstalled(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
+func (p *Pacman) VersionScheme() version.Scheme {
+	return version.Pacman
+}
+
+func (p *Pacman) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, p.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, p.name, nil, nil), nil
 	}
-	stdout, err := exec.Command("pacman", "-Q", pkg.NativePackageName[p.name]).Output()
-	if err != nil {
-		_, ok := err.(*exec.ExitError)
-		if ok {
-			return false, nil
-		}
-		return false, err
+
+	// Both queries exit with 1 if any of the names is unknown, but still
+	// print the packages that were found.
+	stdout, _, err := runner.Output(ctx, p.runner, runner.Command{
+		Name: "pacman",
+		Args: append([]string{"-Q"}, names...),
+	})
+	if err != nil && !runner.IsExitError(err) {
+		return nil, err
 	}
+	installed := p.parseQuery(string(stdout))
 
-	splitoutput := strings.Split(string(stdout), "\n")
-	for _, line := range splitoutput {
-		if strings.HasPrefix(line, pkg.Name) {
-			splitline := strings.Split(line, " ")
-			pkg.Version = strings.TrimSpace(splitline[1])
-		}
+	stdout, _, err = runner.Output(ctx, p.runner, runner.Command{
+		Name: "pacman",
+		Args: append([]string{"-Si"}, names...),
+		Env:  []string{"LC_ALL=C"},
+	})
+	if err != nil && !runner.IsExitError(err) {
+		return nil, err
 	}
+	available := p.parseSyncInfo(string(stdout))
 
-	return true, err
+	return collectStatuses(pkgs, p.name, installed, available), nil
 }
 
-func (p *Pacman) PackageAvailable(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
-	}
-	output, err := exec.Command("pacman", "-Si", pkg.NativePackageName[p.name]).Output()
-	if err != nil {
-		_, ok := err.(*exec.ExitError)
-		if ok {
-			return false, nil
+func (p *Pacman) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, p, pkg)
+	return status.Installed, err
+}
+
+func (p *Pacman) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, p, pkg)
+	return status.Available, err
+}
+
+func (p *Pacman) parseQuery(output string) map[string]string {
+	installed := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			installed[fields[0]] = fields[1]
 		}
-		return false, err
 	}
+	return installed
+}
 
-	reg := regexp.MustCompile(`.*Version.*?:\s+(.*)`)
-	matches := reg.FindStringSubmatch(string(output))
-	pkg.Version = ""
-	noOfMatches := len(matches)
-	if noOfMatches > 1 {
-		pkg.Version = strings.TrimSpace(matches[1])
-	}
+// parseSyncInfo reads the Name and Version fields of each block printed by
+// pacman -Si. A package found in several repositories keeps the first one.
+func (p *Pacman) parseSyncInfo(output string) map[string]string {
+	available := map[string]string{}
+	reg := regexp.MustCompile(`(?m)^(Name|Version)\s*:\s*(\S+)`)
 
-	return true, nil
+	name := ""
+	for _, match := range reg.FindAllStringSubmatch(output, -1) {
+		switch match[1] {
+		case "Name":
+			name = match[2]
+		case "Version":
+			if _, ok := available[name]; name != "" && !ok {
+				available[name] = match[2]
+			}
+			name = ""
+		}
+	}
+	return available
 }
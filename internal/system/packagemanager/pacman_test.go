@@ -0,0 +1,56 @@
+//go:build linux
+
+package packagemanager
+
+import "testing"
+
+func TestPacmanQueryPackages(t *testing.T) {
+	fake := loadFixture(t, "pacman/arch.json")
+	checkQuery(t, NewPacman("arch", fake), fake, []queryTest{
+		{"git", "git", PackageStatus{true, true, "2.47.1-1", "2.47.1-1"}},
+		{"openjdk", "jdk17-openjdk", PackageStatus{true, true, "17.0.13.u11-1", "17.0.13.u11-1"}},
+		{"podman", "podman", PackageStatus{true, false, "5.3.1-1", "5.3.1-1"}},
+		{"vscode", "visual-studio-code-bin", PackageStatus{false, false, "", ""}},
+	}, 2)
+}
+
+func TestPacmanParseSyncInfo(t *testing.T) {
+	fake := loadFixture(t, "pacman/arch.json")
+	output := fake.Responses["pacman -Si git jdk17-openjdk podman visual-studio-code-bin"].Stdout
+
+	want := map[string]string{
+		"git":           "2.47.1-1",
+		"jdk17-openjdk": "17.0.13.u11-1",
+		"podman":        "5.3.1-1",
+	}
+	got := NewPacman("arch", nil).parseSyncInfo(output)
+	if len(got) != len(want) {
+		t.Errorf("parseSyncInfo() = %v, want %v", got, want)
+	}
+	for name, version := range want {
+		if got[name] != version {
+			t.Errorf("parseSyncInfo()[%s] = %q, want %q", name, got[name], version)
+		}
+	}
+}
+
+func TestPacmanProgress(t *testing.T) {
+	tests := []struct {
+		line string
+		want float64
+		ok   bool
+	}{
+		{"(1/2) checking package integrity", 0.25, true},
+		{"(2/2) installing git", 1, true},
+		{"(1/2) removing podman", 0.75, true},
+		{":: Processing package changes...", 0, false},
+		{"(3/2) installing git", 0, false},
+	}
+	pacman := NewPacman("arch", nil)
+	for _, tt := range tests {
+		got, ok := pacman.Progress(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Progress(%q) = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -1,134 +1,220 @@
 package packagemanager
 
+import (
+	"context"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
 type Package struct {
-	Name              string
-	Version           string
+	Name        string
+	Description string
+	Version     string
+	// Constraint restricts the acceptable versions, like ">= 17". It is
+	// evaluated with the version rules of the package manager.
+	Constraint        string
 	NativePackageName map[string]string
-	SystemPackage     bool
-	Library           bool
-	Optional          bool
+	// Variants replace native names on some distributions or releases. They
+	// are applied by ResolveNames.
+	Variants []*Variant
+	// Repositories holds, per package manager, the third-party repository
+	// the package is only available from.
+	Repositories map[string]*Repository
+	// Requires names the packages that have to be installed before this one,
+	// like the JDK for Maven.
+	Requires []string
+	// Managers names the backends that should install the package, in order
+	// of preference, like "flatpak" for desktop applications. The package
+	// manager of the system and the other backends found are tried after
+	// them.
+	Managers []string
+	// Channel selects the release channel with backends that have them, like
+	// "latest/edge" with snap. Empty selects the stable channel.
+	Channel string
+	// Archives holds the release archives the package can be installed from
+	// without root privileges, keyed by platform like "linux/amd64", by
+	// operating system like "linux", or "any".
+	Archives map[string]*Archive
+	// Alternatives are packages that satisfy the same requirement, in order
+	// of preference after this one, like another JDK.
+	Alternatives []*Package
+	// Probes find the tool if it was installed without a package manager.
+	Probes        []*Probe
+	SystemPackage bool
+	Library       bool
+	Optional      bool
+}
+
+// Archive is a release archive of a package. The archive contains a single
+// top-level directory, Bin is the directory with the executables below it.
+//
+// An archive is only installed once it is verified: against SHA256, against
+// the SHA-256 or SHA-512 digest in the file at ChecksumURL, or against the
+// detached signature at SignatureURL made with a key from KeyURL.
+type Archive struct {
+	Version      string
+	URL          string
+	Bin          string
+	SHA256       string
+	ChecksumURL  string
+	SignatureURL string
+	KeyURL       string
+}
+
+// Choices returns the package followed by its alternatives.
+func (p *Package) Choices() []*Package {
+	return append([]*Package{p}, p.Alternatives...)
 }
 
-type packagemap = map[string][]*Package
+// PackageStatus is the result of a status query for a single package. Version
+// is the installed version, or the version that would be installed. Candidate
+// is the newest version the package manager can install.
+type PackageStatus struct {
+	Available bool
+	Installed bool
+	Version   string
+	Candidate string
+}
+
+// Outdated reports whether an installed package can be upgraded to a newer
+// candidate version.
+func (s PackageStatus) Outdated(scheme version.Scheme) bool {
+	return s.Installed && s.Candidate != "" && scheme.Compare(s.Candidate, s.Version) > 0
+}
 
 type PackageManager interface {
 	Name() string
-	Packages() packagemap
-	PackageInstalled(pkg *Package) (bool, error)
-	PackageAvailable(pkg *Package) (bool, error)
-	InstallCommand(pkg *Package) string
-}
-
-func GenerateUniversalPackages() packagemap {
-	return packagemap{
-		// Entwicklungstools
-		"git": {
-			{
-				Name:          "git",
-				SystemPackage: true,
-				NativePackageName: map[string]string{
-					"apt":      "git",
-					"dnf":      "git",
-					"pacman":   "git",
-					"zypper":   "git",
-					"homebrew": "git",
-					"choco":    "git",
-				},
-			},
-		},
-		// Java-Entwicklung
-		"openjdk": {
-			{
-				Name:          "openjdk-17",
-				SystemPackage: true,
-				NativePackageName: map[string]string{
-					"apt":      "openjdk-17-jdk",
-					"dnf":      "java-17-openjdk-devel",
-					"pacman":   "jdk17-openjdk",
-					"zypper":   "java-17-openjdk-devel",
-					"homebrew": "openjdk@17",
-					"choco":    "openjdk-17",
-				},
-			},
-		},
-		// Container-Technologien
-		"podman": {
-			{
-				Name:          "podman",
-				SystemPackage: true,
-				Optional:      true,
-				NativePackageName: map[string]string{
-					"apt":      "podman",
-					"dnf":      "podman",
-					"pacman":   "podman",
-					"zypper":   "podman",
-					"homebrew": "podman",
-					"choco":    "podman",
-				},
-			},
-		},
-		// Entwicklungsumgebungen
-		"vscode": {
-			{
-				Name:          "vscode",
-				SystemPackage: false,
-				Optional:      true,
-				NativePackageName: map[string]string{
-					"apt":      "code",
-					"dnf":      "code",
-					"pacman":   "code",
-					"zypper":   "code",
-					"homebrew": "visual-studio-code",
-					"choco":    "vscode",
-				},
-			},
-		},
-		// Build-Tools
-		"maven": {
-			{
-				Name:          "maven",
-				SystemPackage: true,
-				NativePackageName: map[string]string{
-					"apt":      "maven",
-					"dnf":      "maven",
-					"pacman":   "maven",
-					"zypper":   "maven",
-					"homebrew": "maven",
-					"choco":    "maven",
-				},
-			},
-		},
-		"gradle": {
-			{
-				Name:          "gradle",
-				SystemPackage: true,
-				Optional:      true,
-				NativePackageName: map[string]string{
-					"apt":      "gradle",
-					"dnf":      "gradle",
-					"pacman":   "gradle",
-					"zypper":   "gradle",
-					"homebrew": "gradle",
-					"choco":    "gradle",
-				},
-			},
-		},
-		// Compiler und Entwicklungstools
-		"gcc": {
-			{
-				Name:          "gcc",
-				SystemPackage: true,
-				NativePackageName: map[string]string{
-					"apt":      "build-essential",
-					"dnf":      "gcc",
-					"pacman":   "gcc",
-					"zypper":   "gcc",
-					"homebrew": "gcc",
-					"choco":    "mingw-gcc",
-				},
-			},
-		},
+	// VersionScheme returns the rules the package manager orders versions by.
+	VersionScheme() version.Scheme
+	// QueryPackages resolves the status of all pkgs with as few invocations of
+	// the native tools as possible. Packages the backend does not handle are
+	// reported as neither available nor installed.
+	QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error)
+	PackageInstalled(ctx context.Context, pkg *Package) (bool, error)
+	PackageAvailable(ctx context.Context, pkg *Package) (bool, error)
+	// InstallCommand returns a command that installs all pkgs in a single
+	// transaction. Native names are passed as separate arguments and must
+	// never be interpreted by a shell.
+	InstallCommand(pkgs ...*Package) runner.Command
+	// RemoveCommand returns a command that removes all pkgs in a single
+	// transaction.
+	RemoveCommand(pkgs ...*Package) runner.Command
+	// UpgradeCommand returns a command that upgrades the installed pkgs to
+	// their candidate versions in a single transaction.
+	UpgradeCommand(pkgs ...*Package) runner.Command
+	// NativeNames returns the names under which the backend knows pkgs, as
+	// they are passed to the install and remove commands.
+	NativeNames(pkgs ...*Package) []string
+}
+
+// Batcher is implemented by backends that cannot handle every set of packages
+// in a single command. Batches splits pkgs into sets that each fit into one
+// install, remove or upgrade command.
+type Batcher interface {
+	Batches(pkgs []*Package) [][]*Package
+}
+
+// Installer is implemented by backends that install packages themselves
+// instead of running a native command. Their install, remove and upgrade
+// commands are never run; upgrading installs the new version.
+type Installer interface {
+	Install(ctx context.Context, pkgs []*Package) error
+	Remove(ctx context.Context, pkgs []*Package) error
+	// InstallSteps and RemoveSteps describe for the user what Install and
+	// Remove do.
+	InstallSteps(pkgs []*Package) []string
+	RemoveSteps(pkgs []*Package) []string
+}
+
+// Router is implemented by backends that pass packages on to other backends.
+// Backend returns the one that handles pkgs.
+type Router interface {
+	Backend(pkgs ...*Package) PackageManager
+}
+
+// Scoped is implemented by backends that install either for the current user
+// or for the whole system. SetSystem selects the installation for the whole
+// system, which needs root privileges.
+type Scoped interface {
+	SetSystem(system bool)
+}
+
+// ProgressReporter is implemented by backends whose commands can show how far
+// a transaction got. ReportProgress returns cmd changed to print its progress,
+// which clutters the output on a terminal, and Progress returns the progress
+// from 0 to 1 a line of output of such a command shows, if any.
+type ProgressReporter interface {
+	ReportProgress(cmd runner.Command) runner.Command
+	Progress(line string) (float64, bool)
+}
+
+// Deployer is implemented by backends whose changes only take effect after a
+// reboot. Pending reports whether pkg is installed or removed in a deployment
+// that has not been booted yet, as seen by the last query.
+type Deployer interface {
+	Pending(pkg *Package) bool
+}
+
+// Resolve returns the backend that finally handles pkgs with pm.
+func Resolve(pm PackageManager, pkgs ...*Package) PackageManager {
+	for {
+		router, ok := pm.(Router)
+		if !ok {
+			return pm
+		}
+		pm = router.Backend(pkgs...)
+	}
+}
+
+func queryPackage(ctx context.Context, pm PackageManager, pkg *Package) (PackageStatus, error) {
+	statuses, err := pm.QueryPackages(ctx, []*Package{pkg})
+	return statuses[pkg], err
+}
+
+// nativeNames returns the distinct native names of all system packages in
+// pkgs that are known to the given package manager.
+func nativeNames(pkgs []*Package, manager string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		name := pkg.NativePackageName[manager]
+		if !pkg.SystemPackage || name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// collectStatuses builds the result of QueryPackages from the installed and
+// available versions keyed by native name and updates Package.Version.
+func collectStatuses(pkgs []*Package, manager string, installed map[string]string, available map[string]string) map[*Package]PackageStatus {
+	statuses := map[*Package]PackageStatus{}
+	for _, pkg := range pkgs {
+		if !pkg.SystemPackage {
+			statuses[pkg] = PackageStatus{}
+			continue
+		}
+
+		name := pkg.NativePackageName[manager]
+		installedVersion, isInstalled := installed[name]
+		availableVersion, isAvailable := available[name]
+
+		status := PackageStatus{
+			Available: isAvailable || isInstalled,
+			Installed: isInstalled,
+			Version:   availableVersion,
+			Candidate: availableVersion,
+		}
+		if isInstalled {
+			status.Version = installedVersion
+		}
+		pkg.Version = status.Version
+		statuses[pkg] = status
 	}
+	return statuses
 }
 
 type Dependency struct {
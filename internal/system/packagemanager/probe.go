@@ -0,0 +1,118 @@
+package packagemanager
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+// Probe looks for a tool that may have been installed without a package
+// manager, like a JDK unpacked by hand or Maven installed with SDKMAN.
+type Probe struct {
+	// Binary is the executable looked up on PATH, through the runner, and in
+	// Dirs.
+	Binary string
+	// Dirs are further directories to look in, like
+	// "~/.sdkman/candidates/maven/current/bin". They may start with ~, use
+	// environment variables and contain glob patterns. A directory with a
+	// variable that is not set is skipped. Dirs are always matched against
+	// the real filesystem, tests create the tools they expect there.
+	Dirs []string
+	// VersionArgs make Binary print its version, which the first group of
+	// VersionPattern extracts from stdout and stderr.
+	VersionArgs    []string
+	VersionPattern string
+}
+
+// ProbeResult is a tool found by a probe. Version is empty if it could not
+// be determined.
+type ProbeResult struct {
+	Path    string
+	Version string
+}
+
+// RunProbes runs the probes of pkg and returns every tool found, in the order
+// of the probes and for each probe PATH first, then Dirs.
+func RunProbes(ctx context.Context, r runner.Runner, pkg *Package) []ProbeResult {
+	var results []ProbeResult
+	seen := map[string]bool{}
+	for _, probe := range pkg.Probes {
+		for _, path := range probe.paths(r) {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			results = append(results, ProbeResult{Path: path, Version: probe.version(ctx, r, path)})
+		}
+	}
+	return results
+}
+
+// paths returns the executables the probe finds.
+func (p *Probe) paths(r runner.Runner) []string {
+	var paths []string
+	if path, err := r.LookPath(p.Binary); err == nil {
+		paths = append(paths, path)
+	}
+	for _, dir := range p.Dirs {
+		dir, ok := expandDir(dir)
+		if !ok {
+			continue
+		}
+		matches, _ := filepath.Glob(dir)
+		for _, match := range matches {
+			if path, err := exec.LookPath(filepath.Join(match, p.Binary)); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// version runs the executable at path to find out its version.
+func (p *Probe) version(ctx context.Context, r runner.Runner, path string) string {
+	if len(p.VersionArgs) == 0 || p.VersionPattern == "" {
+		return ""
+	}
+	pattern, err := regexp.Compile(p.VersionPattern)
+	if err != nil {
+		return ""
+	}
+
+	// java -version prints to stderr.
+	stdout, stderr, err := runner.Output(ctx, r, runner.Command{Name: path, Args: p.VersionArgs})
+	if err != nil {
+		return ""
+	}
+	match := pattern.FindSubmatch(append(stdout, stderr...))
+	if len(match) < 2 {
+		return ""
+	}
+	return string(match[1])
+}
+
+// expandDir replaces ~ and environment variables in dir. It fails if a
+// variable is not set, so "$JAVA_HOME/bin" never becomes "/bin".
+func expandDir(dir string) (string, bool) {
+	ok := true
+	dir = os.Expand(dir, func(name string) string {
+		value := os.Getenv(name)
+		if value == "" {
+			ok = false
+		}
+		return value
+	})
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+	return filepath.FromSlash(dir), ok
+}
@@ -0,0 +1,66 @@
+//go:build !windows
+
+package packagemanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+func executable(t *testing.T, dir string, name string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunProbes(t *testing.T) {
+	root := t.TempDir()
+	onPath := executable(t, filepath.Join(root, "usr", "bin"), "java")
+	manual := executable(t, filepath.Join(root, "jdks", "jdk-21.0.5", "bin"), "java")
+	executable(t, filepath.Join(root, "jdks", "jdk-17.0.13"), "java")
+	t.Setenv("JWS_TEST_JDKS", filepath.Join(root, "jdks"))
+	t.Setenv("JWS_TEST_UNSET", "")
+
+	fake := runner.NewFake()
+	fake.Paths["java"] = onPath
+	fake.Add(onPath+" -version", runner.Response{Stderr: "java version \"1.8.0_392\"\nJava(TM) SE Runtime Environment\n"})
+	fake.Add(manual+" -version", runner.Response{Stderr: "openjdk version \"21.0.5\" 2024-10-15 LTS\n"})
+
+	pkg := &Package{
+		Name: "openjdk",
+		Probes: []*Probe{{
+			Binary:         "java",
+			Dirs:           []string{"$JWS_TEST_JDKS/*/bin", "$JWS_TEST_UNSET/bin", "$JWS_TEST_JDKS/jdk-21.0.5/bin"},
+			VersionArgs:    []string{"-version"},
+			VersionPattern: `version "([^"]+)"`,
+		}},
+	}
+	got := RunProbes(context.Background(), fake, pkg)
+	want := []ProbeResult{{onPath, "1.8.0_392"}, {manual, "21.0.5"}}
+	if len(got) != len(want) {
+		t.Fatalf("RunProbes() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("RunProbes()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	unknown := &Package{Name: "maven", Probes: []*Probe{{Binary: "java", VersionArgs: []string{"-v"}, VersionPattern: `Apache Maven (\S+)`}}}
+	if got := RunProbes(context.Background(), fake, unknown); len(got) != 1 || got[0].Version != "" {
+		t.Errorf("RunProbes() with a failing version command = %+v, want the path without version", got)
+	}
+	if got := RunProbes(context.Background(), fake, &Package{Name: "git", Probes: []*Probe{{Binary: "git"}}}); len(got) != 0 {
+		t.Errorf("RunProbes() of a missing tool = %+v", got)
+	}
+}
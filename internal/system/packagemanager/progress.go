@@ -0,0 +1,17 @@
+package packagemanager
+
+import "strconv"
+
+// stepProgress maps step i of n, both as printed by a package manager, to the
+// range from start to end.
+func stepProgress(i string, n string, start float64, end float64) (float64, bool) {
+	step, err := strconv.Atoi(i)
+	if err != nil {
+		return 0, false
+	}
+	steps, err := strconv.Atoi(n)
+	if err != nil || steps == 0 || step > steps {
+		return 0, false
+	}
+	return start + (end-start)*float64(step)/float64(steps), true
+}
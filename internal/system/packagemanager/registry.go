@@ -0,0 +1,66 @@
+package packagemanager
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Registry records the packages installed into a user prefix, keyed by
+// package name.
+type Registry struct {
+	Packages map[string]RegistryEntry `json:"packages"`
+
+	path string
+}
+
+// RegistryEntry is a package installed into a user prefix. URL is the archive
+// it was installed from and SHA256 the digest of that archive, Links are the
+// links to its executables.
+type RegistryEntry struct {
+	Version string   `json:"version"`
+	Path    string   `json:"path"`
+	URL     string   `json:"url,omitempty"`
+	SHA256  string   `json:"sha256,omitempty"`
+	Links   []string `json:"links,omitempty"`
+}
+
+// LoadRegistry reads the registry at path. A missing file is an empty
+// registry.
+func LoadRegistry(path string) (*Registry, error) {
+	r := &Registry{Packages: map[string]RegistryEntry{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	if r.Packages == nil {
+		r.Packages = map[string]RegistryEntry{}
+	}
+	return r, nil
+}
+
+// Save writes the registry. The file is replaced in one step so a crash never
+// leaves a partial registry behind.
+func (r *Registry) Save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
@@ -0,0 +1,106 @@
+package packagemanager
+
+import (
+	"fmt"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+// Repository is a third-party package source a package is only available from
+// once it has been added.
+type Repository struct {
+	// Name identifies the repository and is used for the names of its files.
+	Name        string
+	Description string
+	URL         string
+	KeyURL      string
+	// KeyID is the short id of the signing key, which is needed to remove the
+	// key from the rpm database again.
+	KeyID string
+	// Suite and Components select the part of an apt repository.
+	Suite      string
+	Components []string
+}
+
+// RepositoryFile is a file that has to be installed to set up a repository.
+// Its content is either downloaded from URL or given in Content.
+type RepositoryFile struct {
+	Path    string
+	URL     string
+	Content string
+}
+
+// RepositorySetup lists the steps that add or remove a repository. Files are
+// installed first, then Commands run in order.
+type RepositorySetup struct {
+	Repository *Repository
+	Files      []RepositoryFile
+	Commands   []runner.Command
+}
+
+// Steps describes every step of the setup for the user.
+func (s *RepositorySetup) Steps() []string {
+	var steps []string
+	for _, file := range s.Files {
+		if file.URL != "" {
+			steps = append(steps, fmt.Sprintf("%s von %s", file.Path, file.URL))
+		} else {
+			steps = append(steps, fmt.Sprintf("%s schreiben", file.Path))
+		}
+	}
+	for _, cmd := range s.Commands {
+		steps = append(steps, cmd.Shell())
+	}
+	return steps
+}
+
+// NeedsElevation reports whether any step of the setup needs root privileges.
+func (s *RepositorySetup) NeedsElevation() bool {
+	if len(s.Files) > 0 {
+		return true
+	}
+	for _, cmd := range s.Commands {
+		if cmd.Elevate {
+			return true
+		}
+	}
+	return false
+}
+
+// RepositoryManager is implemented by backends that can add third-party
+// repositories.
+type RepositoryManager interface {
+	AddRepository(repo *Repository) *RepositorySetup
+	RemoveRepository(repo *Repository) *RepositorySetup
+	// RepositoryConfigured reports whether the repository was added by
+	// AddRepository.
+	RepositoryConfigured(repo *Repository) bool
+}
+
+// repoFile renders a repository definition in the format dnf and zypper read
+// from their .repo files.
+func repoFile(repo *Repository, extra string) string {
+	return fmt.Sprintf("[%s]\nname=%s\nbaseurl=%s\nenabled=1\ngpgcheck=1\ngpgkey=%s\n%s",
+		repo.Name, repo.Description, repo.URL, repo.KeyURL, extra)
+}
+
+// rpmKeyRemoval returns the command that removes the signing key of repo from
+// the rpm database, if the key is known.
+func rpmKeyRemoval(repo *Repository) []runner.Command {
+	if repo.KeyID == "" {
+		return nil
+	}
+	return []runner.Command{{
+		Name:    "rpm",
+		Args:    []string{"-e", "--allmatches", "gpg-pubkey-" + repo.KeyID},
+		Elevate: true,
+	}}
+}
+
+func removeFiles(paths ...string) runner.Command {
+	return runner.Command{
+		Name:    "rm",
+		Args:    append([]string{"-f", "--"}, paths...),
+		Elevate: true,
+	}
+}
@@ -0,0 +1,58 @@
+package packagemanager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SortByRequires orders pkgs so every package comes after the packages it
+// requires and otherwise keeps their order. It fails if a package requires
+// one that is not in pkgs or if the requirements form a cycle.
+func SortByRequires(pkgs []*Package) ([]*Package, error) {
+	byName := map[string]*Package{}
+	for _, pkg := range pkgs {
+		byName[pkg.Name] = pkg
+	}
+
+	sorted := make([]*Package, 0, len(pkgs))
+	done := map[*Package]bool{}
+	// path holds the packages being visited, to report a cycle.
+	var path []*Package
+	var visit func(pkg *Package) error
+	visit = func(pkg *Package) error {
+		if done[pkg] {
+			return nil
+		}
+		if i := slices.Index(path, pkg); i >= 0 {
+			var names []string
+			for _, p := range append(path[i:], pkg) {
+				names = append(names, p.Name)
+			}
+			return fmt.Errorf("cycle in requires: %s", strings.Join(names, " -> "))
+		}
+
+		path = append(path, pkg)
+		for _, name := range pkg.Requires {
+			required, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("package %s requires unknown package %s", pkg.Name, name)
+			}
+			if err := visit(required); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+
+		done[pkg] = true
+		sorted = append(sorted, pkg)
+		return nil
+	}
+
+	for _, pkg := range pkgs {
+		if err := visit(pkg); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
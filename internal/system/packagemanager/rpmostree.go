@@ -0,0 +1,191 @@
+//go:build linux
+// +build linux
+
+package packagemanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+// RpmOstree layers packages onto the image of ostree based Fedora variants
+// like Silverblue. Layered packages are part of a new deployment that only
+// becomes active with the next reboot. The packages are those of Fedora, so
+// the native names of dnf are used.
+type RpmOstree struct {
+	name   string
+	osid   string
+	runner runner.Runner
+	// dnf queries the packages of the booted image. Its repositories are
+	// only queried with repoquery set, as dnf is missing on some variants.
+	dnf       *Dnf
+	repoquery bool
+
+	// pending holds the packages the pending deployment installs or removes
+	// as seen by the last query.
+	pending map[string]bool
+	mu      sync.Mutex
+}
+
+func NewRpmOstree(osid string, r runner.Runner, repoquery bool) *RpmOstree {
+	return &RpmOstree{
+		name:      "rpm-ostree",
+		osid:      osid,
+		runner:    r,
+		dnf:       NewDnf(osid, r),
+		repoquery: repoquery,
+		pending:   map[string]bool{},
+	}
+}
+
+func (o *RpmOstree) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "rpm-ostree",
+		Args:    append([]string{"install", "--idempotent", "--"}, nativeNames(pkgs, "dnf")...),
+		Elevate: true,
+	}
+}
+
+func (o *RpmOstree) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "rpm-ostree",
+		Args:    append([]string{"uninstall", "--idempotent", "--"}, nativeNames(pkgs, "dnf")...),
+		Elevate: true,
+	}
+}
+
+// UpgradeCommand upgrades the whole image, rpm-ostree cannot upgrade single
+// packages.
+func (o *RpmOstree) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "rpm-ostree",
+		Args:    []string{"upgrade"},
+		Elevate: true,
+	}
+}
+
+func (o *RpmOstree) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, "dnf")
+}
+
+func (o *RpmOstree) Name() string {
+	return o.name
+}
+
+func (o *RpmOstree) VersionScheme() version.Scheme {
+	return version.RPM
+}
+
+// Pending reports whether pkg changes with the next reboot.
+func (o *RpmOstree) Pending(pkg *Package) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pending[pkg.NativePackageName["dnf"]]
+}
+
+type rpmOstreeStatus struct {
+	Deployments []rpmOstreeDeployment `json:"deployments"`
+}
+
+type rpmOstreeDeployment struct {
+	Booted            bool     `json:"booted"`
+	RequestedPackages []string `json:"requested-packages"`
+}
+
+// QueryPackages combines the packages of the booted image as rpm sees them
+// with the layered packages of the pending deployment. A package layered in
+// the pending deployment counts as installed. Without repoquery the available
+// versions are unknown, then every package is assumed to be available.
+func (o *RpmOstree) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, "dnf")
+	if len(names) == 0 {
+		return collectStatuses(pkgs, "dnf", nil, nil), nil
+	}
+
+	installed, err := o.dnf.queryInstalled(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	available := map[string]string{}
+	if o.repoquery {
+		if available, err = o.dnf.queryAvailable(ctx, names); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, name := range names {
+			available[name] = ""
+		}
+	}
+
+	stdout, _, err := runner.Output(ctx, o.runner, runner.Command{
+		Name: "rpm-ostree",
+		Args: []string{"status", "--json"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	changes, err := o.parseStatus(stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	o.pending = map[string]bool{}
+	for name, layered := range changes {
+		o.pending[name] = true
+		if _, ok := installed[name]; layered && !ok {
+			installed[name] = available[name]
+		}
+	}
+	o.mu.Unlock()
+
+	return collectStatuses(pkgs, "dnf", installed, available), nil
+}
+
+func (o *RpmOstree) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, o, pkg)
+	return status.Installed, err
+}
+
+func (o *RpmOstree) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, o, pkg)
+	return status.Available, err
+}
+
+// parseStatus returns the packages whose layering differs between the booted
+// and the pending deployment, each mapped to whether the pending deployment
+// layers it. rpm-ostree lists the pending deployment first.
+func (o *RpmOstree) parseStatus(output []byte) (map[string]bool, error) {
+	var status rpmOstreeStatus
+	if err := json.Unmarshal(output, &status); err != nil {
+		return nil, fmt.Errorf("invalid rpm-ostree output: %v", err)
+	}
+
+	changes := map[string]bool{}
+	booted := slices.IndexFunc(status.Deployments, func(d rpmOstreeDeployment) bool {
+		return d.Booted
+	})
+	if booted <= 0 {
+		return changes, nil
+	}
+
+	current := status.Deployments[booted].RequestedPackages
+	next := status.Deployments[0].RequestedPackages
+	for _, name := range next {
+		if !slices.Contains(current, name) {
+			changes[name] = true
+		}
+	}
+	for _, name := range current {
+		if !slices.Contains(next, name) {
+			changes[name] = false
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,68 @@
+//go:build linux
+
+package packagemanager
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRpmOstreeQueryPackages(t *testing.T) {
+	fake := loadFixture(t, "rpm-ostree/silverblue-41.json")
+	ostree := NewRpmOstree("fedora", fake, true)
+	tests := []queryTest{
+		{"git", "git", PackageStatus{true, true, "2.47.1-1.fc41", "2.47.1-1.fc41"}},
+		{"maven", "maven", PackageStatus{true, true, "1:3.9.9-1.fc41", "1:3.9.9-1.fc41"}},
+		{"podman-compose", "podman-compose", PackageStatus{true, true, "1.2.0-2.fc41", "1.2.0-2.fc41"}},
+		{"git-lfs", "git-lfs", PackageStatus{true, true, "3.5.1-3.fc41", "3.5.1-3.fc41"}},
+	}
+	// The backend uses the names of dnf.
+	pkgs := make([]*Package, len(tests))
+	for i, tt := range tests {
+		pkgs[i] = testPackage(tt.name, "dnf", tt.native)
+	}
+
+	statuses, err := ostree.QueryPackages(context.Background(), pkgs)
+	if err != nil {
+		t.Fatalf("QueryPackages() error = %v", err)
+	}
+	pending := map[string]bool{"maven": true, "git-lfs": true}
+	for i, tt := range tests {
+		if got := statuses[pkgs[i]]; got != tt.want {
+			t.Errorf("%s: status = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if got := ostree.Pending(pkgs[i]); got != pending[tt.name] {
+			t.Errorf("%s: Pending() = %v, want %v", tt.name, got, pending[tt.name])
+		}
+	}
+
+	if got, want := ostree.InstallCommand(pkgs[1]).String(), "rpm-ostree install --idempotent -- maven"; got != want {
+		t.Errorf("InstallCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestToolbox(t *testing.T) {
+	fake := loadFixture(t, "rpm-ostree/toolbox.json")
+	toolbox := NewToolbox("fedora", fake, "")
+	pkgs := []*Package{testPackage("git", "dnf", "git"), testPackage("maven", "dnf", "maven")}
+
+	statuses, err := toolbox.QueryPackages(context.Background(), pkgs)
+	if err != nil {
+		t.Fatalf("QueryPackages() error = %v", err)
+	}
+	if !statuses[pkgs[0]].Installed || statuses[pkgs[1]].Installed || !statuses[pkgs[1]].Available {
+		t.Errorf("QueryPackages() = %+v", statuses)
+	}
+
+	if got, want := toolbox.InstallCommand(pkgs[1]).String(), "toolbox run sudo dnf install -y -- maven"; got != want {
+		t.Errorf("InstallCommand() = %q, want %q", got, want)
+	}
+	if toolbox.InstallCommand(pkgs[1]).Elevate {
+		t.Error("InstallCommand() is elevated, want sudo inside the container")
+	}
+
+	missing, err := NewToolbox("fedora", loadFixture(t, "rpm-ostree/no-toolbox.json"), "").QueryPackages(context.Background(), pkgs)
+	if err != nil || missing[pkgs[0]].Available {
+		t.Errorf("QueryPackages() without container = %+v, %v, want unavailable", missing, err)
+	}
+}
@@ -0,0 +1,249 @@
+//go:build linux
+// +build linux
+
+package packagemanager
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+const defaultSnapChannel = "latest/stable"
+
+type Snap struct {
+	name   string
+	osid   string
+	runner runner.Runner
+
+	// classic holds the snaps that need classic confinement as seen by the
+	// last query. snap refuses to install them without --classic.
+	classic map[string]bool
+	mu      sync.Mutex
+}
+
+// snapRelease is a release of a snap in one channel.
+type snapRelease struct {
+	Version string
+	Classic bool
+}
+
+func NewSnap(osid string, r runner.Runner) *Snap {
+	return &Snap{
+		name:    "snap",
+		osid:    osid,
+		runner:  r,
+		classic: map[string]bool{},
+	}
+}
+
+// channel returns the channel pkg is installed from, with the track spelled
+// out like in the output of snap info.
+func (s *Snap) channel(pkg *Package) string {
+	switch {
+	case pkg.Channel == "":
+		return defaultSnapChannel
+	case !strings.Contains(pkg.Channel, "/"):
+		return "latest/" + pkg.Channel
+	}
+	return pkg.Channel
+}
+
+// InstallCommand installs pkgs. snap only accepts --classic and --channel for
+// a single snap, so Batches puts every snap that needs them into its own
+// command.
+func (s *Snap) InstallCommand(pkgs ...*Package) runner.Command {
+	args := []string{"install"}
+	if names := nativeNames(pkgs, s.name); len(names) == 1 {
+		s.mu.Lock()
+		classic := s.classic[names[0]]
+		s.mu.Unlock()
+		if classic {
+			args = append(args, "--classic")
+		}
+		if channel := s.channel(pkgs[0]); channel != defaultSnapChannel {
+			args = append(args, "--channel="+channel)
+		}
+	}
+
+	return runner.Command{
+		Name:    "snap",
+		Args:    append(append(args, "--"), nativeNames(pkgs, s.name)...),
+		Elevate: true,
+	}
+}
+
+func (s *Snap) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "snap",
+		Args:    append([]string{"remove", "--"}, nativeNames(pkgs, s.name)...),
+		Elevate: true,
+	}
+}
+
+// UpgradeCommand refreshes pkgs in the channels they are tracking.
+func (s *Snap) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "snap",
+		Args:    append([]string{"refresh", "--"}, nativeNames(pkgs, s.name)...),
+		Elevate: true,
+	}
+}
+
+func (s *Snap) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, s.name)
+}
+
+// Batches puts snaps with classic confinement or another than the default
+// channel into batches of their own.
+func (s *Snap) Batches(pkgs []*Package) [][]*Package {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var batches [][]*Package
+	var plain []*Package
+	for _, pkg := range pkgs {
+		if s.classic[pkg.NativePackageName[s.name]] || s.channel(pkg) != defaultSnapChannel {
+			batches = append(batches, []*Package{pkg})
+		} else {
+			plain = append(plain, pkg)
+		}
+	}
+	if len(plain) > 0 {
+		batches = append([][]*Package{plain}, batches...)
+	}
+	return batches
+}
+
+func (s *Snap) Name() string {
+	return s.name
+}
+
+// VersionScheme returns generic rules. Snaps choose their versions freely,
+// some use commit hashes.
+func (s *Snap) VersionScheme() version.Scheme {
+	return version.Generic
+}
+
+func (s *Snap) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, s.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, s.name, nil, nil), nil
+	}
+
+	// snap list fails if snapd is not running, then nothing is installed.
+	stdout, _, err := runner.Output(ctx, s.runner, runner.Command{Name: "snap", Args: []string{"list"}})
+	if err != nil && !runner.IsExitError(err) {
+		return nil, err
+	}
+	installed, installedClassic := s.parseList(string(stdout))
+
+	// snap info fails if one of the names is unknown but still describes
+	// the others.
+	stdout, _, err = runner.Output(ctx, s.runner, runner.Command{
+		Name: "snap",
+		Args: append([]string{"info", "--"}, names...),
+	})
+	if err != nil && !runner.IsExitError(err) {
+		return nil, err
+	}
+	releases := s.parseInfo(string(stdout))
+
+	available := map[string]string{}
+	s.mu.Lock()
+	for _, pkg := range pkgs {
+		name := pkg.NativePackageName[s.name]
+		if name == "" {
+			continue
+		}
+		release, ok := releases[name][s.channel(pkg)]
+		if ok {
+			available[name] = release.Version
+		}
+		s.classic[name] = installedClassic[name] || release.Classic
+	}
+	s.mu.Unlock()
+
+	return collectStatuses(pkgs, s.name, installed, available), nil
+}
+
+func (s *Snap) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, s, pkg)
+	return status.Installed, err
+}
+
+func (s *Snap) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, s, pkg)
+	return status.Available, err
+}
+
+// parseList returns the versions of all installed snaps and which of them use
+// classic confinement, from the columns Name, Version, Rev, Tracking,
+// Publisher and Notes.
+func (s *Snap) parseList(output string) (installed map[string]string, classic map[string]bool) {
+	installed = map[string]string{}
+	classic = map[string]bool{}
+	for i, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 2 {
+			continue
+		}
+		installed[fields[0]] = fields[1]
+		if len(fields) >= 6 && strings.Contains(fields[5], "classic") {
+			classic[fields[0]] = true
+		}
+	}
+	return installed, classic
+}
+
+// parseInfo returns the releases of every snap by channel. A channel shown as
+// "↑" has the same release as the one above it, closed channels are left out.
+func (s *Snap) parseInfo(output string) map[string]map[string]snapRelease {
+	releases := map[string]map[string]snapRelease{}
+	name := ""
+	inChannels := false
+	var previous snapRelease
+	var previousOpen bool
+
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, " ") {
+			key, value, _ := strings.Cut(line, ":")
+			inChannels = key == "channels"
+			if key == "name" {
+				name = strings.TrimSpace(value)
+				releases[name] = map[string]snapRelease{}
+				previousOpen = false
+			}
+			continue
+		}
+		if !inChannels || name == "" {
+			continue
+		}
+
+		channel, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		fields := strings.Fields(value)
+		if !found || len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "↑":
+			if previousOpen {
+				releases[name][channel] = previous
+			}
+		case "–", "--":
+			previousOpen = false
+		default:
+			previous = snapRelease{
+				Version: fields[0],
+				Classic: fields[len(fields)-1] == "classic",
+			}
+			previousOpen = true
+			releases[name][channel] = previous
+		}
+	}
+	return releases
+}
@@ -0,0 +1,125 @@
+//go:build linux
+
+package packagemanager
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+)
+
+func TestSnapQueryPackages(t *testing.T) {
+	fake := loadFixture(t, "snap/ubuntu-24.04.json")
+	checkQuery(t, NewSnap("ubuntu", fake), fake, []queryTest{
+		{"vscode", "code", PackageStatus{true, true, "f1a4fb10", "fabdb6a3"}},
+		{"intellij", "intellij-idea-community", PackageStatus{true, false, "2024.3.1.1", "2024.3.1.1"}},
+		{"postman", "postman", PackageStatus{false, false, "", ""}},
+	}, 2)
+}
+
+func TestSnapChannels(t *testing.T) {
+	snap := NewSnap("ubuntu", loadFixture(t, "snap/ubuntu-24.04.json"))
+	tests := []struct {
+		channel string
+		want    PackageStatus
+	}{
+		{"edge", PackageStatus{true, false, "2025.1", "2025.1"}},
+		{"latest/beta", PackageStatus{false, false, "", ""}},
+		{"2023.3/stable", PackageStatus{true, false, "2023.3.8", "2023.3.8"}},
+	}
+
+	for _, tt := range tests {
+		pkg := testPackage("intellij", "snap", "intellij-idea-community")
+		pkg.Channel = tt.channel
+		code := testPackage("vscode", "snap", "code")
+		postman := testPackage("postman", "snap", "postman")
+
+		statuses, err := snap.QueryPackages(context.Background(), []*Package{code, pkg, postman})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := statuses[pkg]; got != tt.want {
+			t.Errorf("%s: status = %+v, want %+v", tt.channel, got, tt.want)
+		}
+	}
+}
+
+func TestSnapInstallCommand(t *testing.T) {
+	snap := NewSnap("ubuntu", loadFixture(t, "snap/ubuntu-24.04.json"))
+	code := testPackage("vscode", "snap", "code")
+	intellij := testPackage("intellij", "snap", "intellij-idea-community")
+	intellij.Channel = "edge"
+	postman := testPackage("postman", "snap", "postman")
+
+	if _, err := snap.QueryPackages(context.Background(), []*Package{code, intellij, postman}); err != nil {
+		t.Fatal(err)
+	}
+
+	batches := snap.Batches([]*Package{code, intellij, postman})
+	want := [][]*Package{{postman}, {code}, {intellij}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Fatalf("Batches() = %v, want %v", batches, want)
+	}
+
+	wantCommands := []runner.Command{
+		{Name: "snap", Args: []string{"install", "--", "postman"}, Elevate: true},
+		{Name: "snap", Args: []string{"install", "--classic", "--", "code"}, Elevate: true},
+		{Name: "snap", Args: []string{"install", "--classic", "--channel=latest/edge", "--", "intellij-idea-community"}, Elevate: true},
+	}
+	for i, batch := range batches {
+		if got := snap.InstallCommand(batch...); !reflect.DeepEqual(got, wantCommands[i]) {
+			t.Errorf("InstallCommand(%s) = %#v, want %#v", batch[0].Name, got, wantCommands[i])
+		}
+	}
+}
+
+func TestCombinedQueryPackages(t *testing.T) {
+	fake := loadFixture(t, "snap/ubuntu-24.04-combined.json")
+	combined := NewCombined(NewApt("ubuntu", fake), NewSnap("ubuntu", fake))
+
+	git := testPackage("git", "apt", "git")
+	vscode := &Package{
+		Name:              "vscode",
+		SystemPackage:     true,
+		NativePackageName: map[string]string{"apt": "code", "snap": "code"},
+	}
+	openjdk := testPackage("openjdk", "apt", "openjdk-17-jdk")
+	intellij := testPackage("intellij", "snap", "intellij-idea-community")
+	pkgs := []*Package{git, vscode, openjdk, intellij}
+
+	statuses, err := combined.QueryPackages(context.Background(), pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[*Package]PackageStatus{
+		git:      {true, true, "1:2.43.0-1ubuntu7.1", "1:2.43.0-1ubuntu7.1"},
+		vscode:   {true, true, "f1a4fb10", "fabdb6a3"},
+		openjdk:  {true, false, "17.0.13+11-2ubuntu1~24.04", "17.0.13+11-2ubuntu1~24.04"},
+		intellij: {true, false, "2024.3.1.1", "2024.3.1.1"},
+	}
+	for _, pkg := range pkgs {
+		if got := statuses[pkg]; got != want[pkg] {
+			t.Errorf("%s: status = %+v, want %+v", pkg.Name, got, want[pkg])
+		}
+	}
+
+	var got []string
+	for _, batch := range combined.Batches(pkgs) {
+		got = append(got, combined.InstallCommand(batch...).String())
+	}
+	wantCommands := []string{
+		"apt install -y -- git openjdk-17-jdk",
+		"snap install --classic -- code",
+		"snap install --classic -- intellij-idea-community",
+	}
+	if !reflect.DeepEqual(got, wantCommands) {
+		t.Errorf("install commands = %q, want %q", got, wantCommands)
+	}
+
+	if got := combined.RemoveCommand(vscode).String(); got != "snap remove -- code" {
+		t.Errorf("RemoveCommand(vscode) = %q", got)
+	}
+}
@@ -0,0 +1,114 @@
+//go:build linux
+// +build linux
+
+package packagemanager
+
+import (
+	"context"
+	"io"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+// Toolbox installs packages with dnf into a toolbox container, which leaves
+// the image of ostree based systems unchanged. The programs are only
+// available inside the container. The packages are those of Fedora, so the
+// native names of dnf are used.
+type Toolbox struct {
+	name   string
+	osid   string
+	runner runner.Runner
+	// container is the toolbox to install into, empty selects the default
+	// one. It has to be created with toolbox create beforehand.
+	container string
+	dnf       *Dnf
+}
+
+func NewToolbox(osid string, r runner.Runner, container string) *Toolbox {
+	t := &Toolbox{
+		name:      "toolbox",
+		osid:      osid,
+		runner:    r,
+		container: container,
+	}
+	t.dnf = NewDnf(osid, toolboxRunner{t})
+	return t
+}
+
+// wrap runs cmd inside the container. The user may use sudo in a toolbox
+// without a password, so the command itself is never elevated.
+func (t *Toolbox) wrap(cmd runner.Command) runner.Command {
+	args := []string{"run"}
+	if t.container != "" {
+		args = append(args, "--container", t.container)
+	}
+	if cmd.Elevate {
+		args = append(args, "sudo")
+	}
+	if len(cmd.Env) > 0 {
+		args = append(append(args, "env"), cmd.Env...)
+	}
+	args = append(append(args, cmd.Name), cmd.Args...)
+
+	return runner.Command{Name: "toolbox", Args: args}
+}
+
+func (t *Toolbox) InstallCommand(pkgs ...*Package) runner.Command {
+	return t.wrap(t.dnf.InstallCommand(pkgs...))
+}
+
+func (t *Toolbox) RemoveCommand(pkgs ...*Package) runner.Command {
+	return t.wrap(t.dnf.RemoveCommand(pkgs...))
+}
+
+func (t *Toolbox) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return t.wrap(t.dnf.UpgradeCommand(pkgs...))
+}
+
+func (t *Toolbox) NativeNames(pkgs ...*Package) []string {
+	return t.dnf.NativeNames(pkgs...)
+}
+
+func (t *Toolbox) Name() string {
+	return t.name
+}
+
+func (t *Toolbox) VersionScheme() version.Scheme {
+	return version.RPM
+}
+
+// QueryPackages queries dnf inside the container. A container that does not
+// exist makes every package unavailable.
+func (t *Toolbox) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	statuses, err := t.dnf.QueryPackages(ctx, pkgs)
+	if runner.IsExitError(err) {
+		return collectStatuses(pkgs, "dnf", nil, nil), nil
+	}
+	return statuses, err
+}
+
+func (t *Toolbox) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, t, pkg)
+	return status.Installed, err
+}
+
+func (t *Toolbox) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, t, pkg)
+	return status.Available, err
+}
+
+// toolboxRunner runs the queries of dnf inside the container of a Toolbox.
+type toolboxRunner struct {
+	toolbox *Toolbox
+}
+
+func (r toolboxRunner) Run(ctx context.Context, cmd runner.Command, stdin io.Reader, stdout, stderr io.Writer) error {
+	return r.toolbox.runner.Run(ctx, r.toolbox.wrap(cmd), stdin, stdout, stderr)
+}
+
+// LookPath looks on the host, the queries only run commands the container
+// image is known to have.
+func (r toolboxRunner) LookPath(name string) (string, error) {
+	return r.toolbox.runner.LookPath(name)
+}
@@ -0,0 +1,315 @@
+package packagemanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+// UserPrefix installs packages from their release archives into a directory
+// of the current user, so no root privileges are needed. Every package gets
+// its own directory below pkgs, links to its executables are placed in bin
+// and the installed versions are tracked in registry.json. Downloaded
+// archives are kept in a cache directory, which may also be filled in advance.
+type UserPrefix struct {
+	name     string
+	dir      string
+	cacheDir string
+	client   *http.Client
+	// runner runs gpgv to verify signatures.
+	runner runner.Runner
+
+	// mu serializes changes to the registry.
+	mu sync.Mutex
+}
+
+func NewUserPrefix(dir string, cacheDir string, r runner.Runner) *UserPrefix {
+	return &UserPrefix{
+		name:     "user",
+		dir:      dir,
+		cacheDir: cacheDir,
+		client:   http.DefaultClient,
+		runner:   r,
+	}
+}
+
+// DefaultPrefix returns the data directory of the current user for the
+// installed packages, like ~/.local/share/jws on Linux.
+func DefaultPrefix() (string, error) {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "jws"), nil
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		if localAppData := os.Getenv("LOCALAPPDATA"); localAppData != "" {
+			return filepath.Join(localAppData, "jws"), nil
+		}
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Application Support", "jws"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "jws"), nil
+}
+
+// DefaultCacheDir returns the cache directory of the current user for
+// downloaded archives.
+func DefaultCacheDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "jws", "archives"), nil
+}
+
+func (u *UserPrefix) Name() string {
+	return u.name
+}
+
+func (u *UserPrefix) VersionScheme() version.Scheme {
+	return version.Generic
+}
+
+// BinDir returns the directory with the links to the executables, which has
+// to be on the PATH.
+func (u *UserPrefix) BinDir() string {
+	return filepath.Join(u.dir, "bin")
+}
+
+func (u *UserPrefix) registry() (*Registry, error) {
+	return LoadRegistry(filepath.Join(u.dir, "registry.json"))
+}
+
+func (u *UserPrefix) packageDir(pkg *Package, archive *Archive) string {
+	return filepath.Join(u.dir, "pkgs", pkg.Name, archive.Version)
+}
+
+// archive returns the release archive of pkg for the current platform.
+func archive(pkg *Package) *Archive {
+	for _, key := range []string{runtime.GOOS + "/" + runtime.GOARCH, runtime.GOOS, "any"} {
+		if archive := pkg.Archives[key]; archive != nil {
+			return archive
+		}
+	}
+	return nil
+}
+
+// QueryPackages looks the packages up in the registry. Packages whose
+// directory was deleted by hand count as missing.
+func (u *UserPrefix) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	registry, err := u.registry()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := map[*Package]PackageStatus{}
+	for _, pkg := range pkgs {
+		var status PackageStatus
+		if archive := archive(pkg); archive != nil {
+			status = PackageStatus{Available: true, Version: archive.Version, Candidate: archive.Version}
+		}
+		if entry, ok := registry.Packages[pkg.Name]; ok {
+			if _, err := os.Stat(entry.Path); err == nil {
+				status.Available = true
+				status.Installed = true
+				status.Version = entry.Version
+			}
+		}
+		pkg.Version = status.Version
+		statuses[pkg] = status
+	}
+	return statuses, nil
+}
+
+func (u *UserPrefix) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, u, pkg)
+	return status.Installed, err
+}
+
+func (u *UserPrefix) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, u, pkg)
+	return status.Available, err
+}
+
+// InstallCommand, RemoveCommand and UpgradeCommand return empty commands, the
+// packages are installed by Install and Remove.
+func (u *UserPrefix) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{}
+}
+
+func (u *UserPrefix) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{}
+}
+
+func (u *UserPrefix) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{}
+}
+
+// NativeNames returns the names of the packages that have an archive for the
+// current platform.
+func (u *UserPrefix) NativeNames(pkgs ...*Package) []string {
+	var names []string
+	for _, pkg := range pkgs {
+		if archive(pkg) != nil {
+			names = append(names, pkg.Name)
+		}
+	}
+	return names
+}
+
+func (u *UserPrefix) InstallSteps(pkgs []*Package) []string {
+	var steps []string
+	for _, pkg := range pkgs {
+		if archive := archive(pkg); archive != nil {
+			steps = append(steps, fmt.Sprintf("%s %s von %s nach %s entpacken",
+				pkg.Name, archive.Version, archive.URL, u.packageDir(pkg, archive)))
+		}
+	}
+	return steps
+}
+
+func (u *UserPrefix) RemoveSteps(pkgs []*Package) []string {
+	registry, err := u.registry()
+	if err != nil {
+		return nil
+	}
+
+	var steps []string
+	for _, pkg := range pkgs {
+		if entry, ok := registry.Packages[pkg.Name]; ok {
+			steps = append(steps, fmt.Sprintf("%s entfernen", entry.Path))
+		}
+	}
+	return steps
+}
+
+// Install downloads, verifies and extracts the archives of pkgs and replaces
+// versions installed before.
+func (u *UserPrefix) Install(ctx context.Context, pkgs []*Package) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	registry, err := u.registry()
+	if err != nil {
+		return err
+	}
+
+	for _, pkg := range pkgs {
+		archive := archive(pkg)
+		if archive == nil {
+			return fmt.Errorf("%s: no archive for %s/%s", pkg.Name, runtime.GOOS, runtime.GOARCH)
+		}
+
+		path, digest, err := u.fetch(ctx, archive)
+		if err != nil {
+			return fmt.Errorf("%s: %v", pkg.Name, err)
+		}
+		dir := u.packageDir(pkg, archive)
+		if err := extractAtomic(path, archive.URL, dir); err != nil {
+			return fmt.Errorf("%s: %v", pkg.Name, err)
+		}
+
+		// The links of the old version may have the same names as the new
+		// ones, so the old version goes first.
+		if old, ok := registry.Packages[pkg.Name]; ok && old.Path != dir {
+			if err := removeEntry(old); err != nil {
+				return fmt.Errorf("%s: %v", pkg.Name, err)
+			}
+		}
+
+		links, err := u.link(filepath.Join(dir, archive.Bin))
+		if err != nil {
+			return fmt.Errorf("%s: %v", pkg.Name, err)
+		}
+		registry.Packages[pkg.Name] = RegistryEntry{
+			Version: archive.Version,
+			Path:    dir,
+			URL:     archive.URL,
+			SHA256:  digest,
+			Links:   links,
+		}
+		if err := registry.Save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove deletes the directories and links of pkgs.
+func (u *UserPrefix) Remove(ctx context.Context, pkgs []*Package) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	registry, err := u.registry()
+	if err != nil {
+		return err
+	}
+
+	for _, pkg := range pkgs {
+		entry, ok := registry.Packages[pkg.Name]
+		if !ok {
+			continue
+		}
+		if err := removeEntry(entry); err != nil {
+			return fmt.Errorf("%s: %v", pkg.Name, err)
+		}
+		delete(registry.Packages, pkg.Name)
+	}
+	return registry.Save()
+}
+
+func removeEntry(entry RegistryEntry) error {
+	for _, link := range entry.Links {
+		if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.RemoveAll(entry.Path)
+}
+
+// link places links to all executables in bin into BinDir. Windows gets no
+// links, there bin itself has to be on the PATH.
+func (u *UserPrefix) link(bin string) ([]string, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(u.BinDir(), 0o755); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(bin)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || info.Mode()&0o111 == 0 {
+			continue
+		}
+
+		link := filepath.Join(u.BinDir(), entry.Name())
+		os.Remove(link)
+		if err := os.Symlink(filepath.Join(bin, entry.Name()), link); err != nil {
+			return links, err
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
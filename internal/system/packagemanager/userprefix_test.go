@@ -0,0 +1,147 @@
+package packagemanager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// tarGz builds an archive with a single top-level directory from files, which
+// map names to contents. Files below bin are executable.
+func tarGz(t *testing.T, top string, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		mode := int64(0o644)
+		if filepath.Dir(name) == "bin" {
+			mode = 0o755
+		}
+		err := tw.WriteHeader(&tar.Header{
+			Name:     top + "/" + name,
+			Mode:     mode,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// archiveServer serves files by path and counts the requests per path.
+func archiveServer(t *testing.T, files map[string][]byte) (*httptest.Server, map[string]int) {
+	t.Helper()
+
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestUserPrefixInstall(t *testing.T) {
+	oldArchive := tarGz(t, "apache-maven-3.9.8", map[string]string{"bin/mvn": "#!/bin/sh\n", "lib/maven.jar": "old"})
+	newArchive := tarGz(t, "apache-maven-3.9.9", map[string]string{"bin/mvn": "#!/bin/sh\n", "lib/maven.jar": "new"})
+	server, _ := archiveServer(t, map[string][]byte{
+		"/maven-3.9.8.tar.gz": oldArchive,
+		"/maven-3.9.9.tar.gz": newArchive,
+	})
+
+	dir := t.TempDir()
+	prefix := NewUserPrefix(dir, t.TempDir(), nil)
+	ctx := context.Background()
+	maven := &Package{
+		Name: "maven",
+		Archives: map[string]*Archive{"any": {
+			Version: "3.9.8",
+			URL:     server.URL + "/maven-3.9.8.tar.gz",
+			Bin:     "bin",
+			SHA256:  sha256Hex(oldArchive),
+		}},
+	}
+
+	status, err := queryPackage(ctx, prefix, maven)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (PackageStatus{true, false, "3.9.8", "3.9.8"}); status != want {
+		t.Errorf("status before install = %+v, want %+v", status, want)
+	}
+
+	if err := prefix.Install(ctx, []*Package{maven}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	maven.Archives["any"] = &Archive{
+		Version: "3.9.9",
+		URL:     server.URL + "/maven-3.9.9.tar.gz",
+		Bin:     "bin",
+		SHA256:  sha256Hex(newArchive),
+	}
+	status, err = queryPackage(ctx, prefix, maven)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (PackageStatus{true, true, "3.9.8", "3.9.9"}); status != want {
+		t.Errorf("status after install = %+v, want %+v", status, want)
+	}
+
+	if err := prefix.Install(ctx, []*Package{maven}); err != nil {
+		t.Fatalf("Install() of the new version error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "pkgs", "maven", "3.9.9", "lib", "maven.jar"))
+	if err != nil || string(data) != "new" {
+		t.Errorf("maven.jar = %q, %v, want %q", data, err, "new")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pkgs", "maven", "3.9.8")); !os.IsNotExist(err) {
+		t.Errorf("old version was not removed: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		target, err := os.Readlink(filepath.Join(prefix.BinDir(), "mvn"))
+		if want := filepath.Join(dir, "pkgs", "maven", "3.9.9", "bin", "mvn"); err != nil || target != want {
+			t.Errorf("link to mvn = %q, %v, want %q", target, err, want)
+		}
+	}
+
+	if err := prefix.Remove(ctx, []*Package{maven}); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if installed, _ := prefix.PackageInstalled(ctx, maven); installed {
+		t.Error("maven is still installed after Remove()")
+	}
+	if _, err := os.Lstat(filepath.Join(prefix.BinDir(), "mvn")); !os.IsNotExist(err) {
+		t.Errorf("link to mvn was not removed: %v", err)
+	}
+}
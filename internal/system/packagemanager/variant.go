@@ -0,0 +1,86 @@
+package packagemanager
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/operatingsystem"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
+)
+
+// Variant is the native name of a package for one package manager on some
+// distributions or releases, like openjdk-21-jdk with apt on Ubuntu 24.04 and
+// newer. An empty Name marks the package as missing there.
+type Variant struct {
+	Manager string
+	// OS matches the ID of the system or one of the distributions in its
+	// ID_LIKE. Empty matches every system.
+	OS string
+	// Versions restricts the VERSION_ID of the system, like ">= 24.04".
+	// Versions are compared with generic rules and only apply to the
+	// distribution named by OS itself, as derived distributions number
+	// their releases differently.
+	Versions string
+	Name     string
+}
+
+// specificity ranks how closely v matches system, 0 if it does not match at
+// all. The ID of the system is closer than the distributions it derives
+// from, and a variant with versions is closer than one without.
+func (v *Variant) specificity(system *operatingsystem.OS) (int, error) {
+	var constraint *version.Constraint
+	if v.Versions != "" {
+		var err error
+		if constraint, err = version.ParseConstraint(v.Versions); err != nil {
+			return 0, err
+		}
+	}
+
+	rank := 1
+	switch {
+	case v.OS == "":
+	case v.OS == system.ID:
+		rank += len(system.IDLike) + 1
+	case slices.Contains(system.IDLike, v.OS):
+		rank += len(system.IDLike) - slices.Index(system.IDLike, v.OS)
+	default:
+		return 0, nil
+	}
+
+	if constraint == nil {
+		return rank * 2, nil
+	}
+	if v.OS != system.ID || system.Version == "" || system.Version == "Unknown" || !constraint.Check(version.Generic, system.Version) {
+		return 0, nil
+	}
+	return rank*2 + 1, nil
+}
+
+// ResolveNames sets the native names of pkgs for system. For every package
+// manager the most specific matching variant wins, the first one on a tie.
+// Without a matching variant the name in NativePackageName stays. The names
+// are replaced in place, so ResolveNames is called once per catalog. The
+// names of alternatives are resolved as well.
+func ResolveNames(pkgs []*Package, system *operatingsystem.OS) error {
+	for _, pkg := range pkgs {
+		if err := ResolveNames(pkg.Alternatives, system); err != nil {
+			return err
+		}
+		best := map[string]int{}
+		for _, variant := range pkg.Variants {
+			rank, err := variant.specificity(system)
+			if err != nil {
+				return fmt.Errorf("package %s: %v", pkg.Name, err)
+			}
+			if rank == 0 || rank <= best[variant.Manager] {
+				continue
+			}
+			best[variant.Manager] = rank
+			if pkg.NativePackageName == nil {
+				pkg.NativePackageName = map[string]string{}
+			}
+			pkg.NativePackageName[variant.Manager] = variant.Name
+		}
+	}
+	return nil
+}
@@ -4,98 +4,157 @@
 package packagemanager
 
 import (
-	"os/exec"
-	"regexp"
-	"strings"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+
+	"github.com/PatrykHegenberg/jws_gui/internal/system/runner"
+	"github.com/PatrykHegenberg/jws_gui/internal/system/version"
 )
 
 type Zypper struct {
-	name string
-	osid string
+	name   string
+	osid   string
+	runner runner.Runner
 }
 
-func NewZypper(osid string) *Zypper {
+func NewZypper(osid string, r runner.Runner) *Zypper {
 	return &Zypper{
-		name: "zypper",
-		osid: osid,
+		name:   "zypper",
+		osid:   osid,
+		runner: r,
 	}
 }
 
-func (z *Zypper) Packages() packagemap {
-	universalPackages := GenerateUniversalPackages()
-	aptSpecificPackages := packagemap{}
-	for key, value := range aptSpecificPackages {
-		universalPackages[key] = value
+func (z *Zypper) InstallCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "zypper",
+		Args:    append([]string{"--non-interactive", "install", "--"}, nativeNames(pkgs, z.name)...),
+		Elevate: true,
 	}
+}
 
-	return universalPackages
+func (z *Zypper) RemoveCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "zypper",
+		Args:    append([]string{"--non-interactive", "remove", "--"}, nativeNames(pkgs, z.name)...),
+		Elevate: true,
+	}
 }
 
-func (z *Zypper) InstallCommand(pkg *Package) string {
-	if pkg.SystemPackage == false {
-		return pkg.NativePackageName[z.osid]
+func (z *Zypper) UpgradeCommand(pkgs ...*Package) runner.Command {
+	return runner.Command{
+		Name:    "zypper",
+		Args:    append([]string{"--non-interactive", "update", "--"}, nativeNames(pkgs, z.name)...),
+		Elevate: true,
 	}
-	return "zypper in " + pkg.NativePackageName[z.name] + " -y"
 }
 
-func (z *Zypper) Name() string {
-	return z.name
+func (z *Zypper) NativeNames(pkgs ...*Package) []string {
+	return nativeNames(pkgs, z.name)
 }
 
-func (z *Zypper) PackageInstalled(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
-	}
-	cmd := exec.Command("zypper", "info", pkg.NativePackageName[z.name])
-	cmd.Env = []string{"LANGUAGE", "en_US.utf-8"}
-	stdout, err := cmd.Output()
-	if err != nil {
-		_, ok := err.(*exec.ExitError)
-		if ok {
-			return false, nil
-		}
-		return false, err
+func (z *Zypper) repoPath(repo *Repository) string {
+	return "/etc/zypp/repos.d/" + repo.Name + ".repo"
+}
+
+func (z *Zypper) AddRepository(repo *Repository) *RepositorySetup {
+	return &RepositorySetup{
+		Repository: repo,
+		Files:      []RepositoryFile{{Path: z.repoPath(repo), Content: repoFile(repo, "type=rpm-md\nautorefresh=1\n")}},
+		Commands: []runner.Command{
+			{Name: "rpm", Args: []string{"--import", repo.KeyURL}, Elevate: true},
+			{Name: "zypper", Args: []string{"--non-interactive", "refresh", repo.Name}, Elevate: true},
+		},
 	}
-	reg := regexp.MustCompile(`.*Installed\s*:\s*(Yes)\s*`)
-	matches := reg.FindStringSubmatch(string(stdout))
-	pkg.Version = ""
-	noOfMatches := len(matches)
-	if noOfMatches > 1 {
-		z.getPackageVersion(pkg, string(stdout))
+}
+
+func (z *Zypper) RemoveRepository(repo *Repository) *RepositorySetup {
+	return &RepositorySetup{
+		Repository: repo,
+		Commands: append([]runner.Command{
+			{Name: "zypper", Args: []string{"--non-interactive", "removerepo", repo.Name}, Elevate: true},
+		}, rpmKeyRemoval(repo)...),
 	}
-	return noOfMatches > 1, err
 }
 
-func (z *Zypper) PackageAvailable(pkg *Package) (bool, error) {
-	if pkg.SystemPackage == false {
-		return false, nil
+func (z *Zypper) RepositoryConfigured(repo *Repository) bool {
+	_, err := os.Stat(z.repoPath(repo))
+	return err == nil
+}
+
+func (z *Zypper) Name() string {
+	return z.name
+}
+
+func (z *Zypper) VersionScheme() version.Scheme {
+	return version.RPM
+}
+
+type zypperStream struct {
+	Solvables []struct {
+		Status     string `xml:"status,attr"`
+		Name       string `xml:"name,attr"`
+		Edition    string `xml:"edition,attr"`
+		Repository string `xml:"repository,attr"`
+	} `xml:"search-result>solvable-list>solvable"`
+}
+
+func (z *Zypper) QueryPackages(ctx context.Context, pkgs []*Package) (map[*Package]PackageStatus, error) {
+	names := nativeNames(pkgs, z.name)
+	if len(names) == 0 {
+		return collectStatuses(pkgs, z.name, nil, nil), nil
 	}
-	env := []string{"LANGUAGE", "en_US.utf-8"}
-	cmd := exec.Command("zypper", "info", pkg.NativePackageName[z.name])
-	cmd.Env = env
-	stdout, err := cmd.Output()
-	if err != nil {
-		_, ok := err.(*exec.ExitError)
-		if ok {
-			return false, nil
-		}
-		return false, err
+
+	// zypper exits with 104 if none of the names were found.
+	stdout, _, err := runner.Output(ctx, z.runner, runner.Command{
+		Name: "zypper",
+		Args: append([]string{"--xmlout", "--non-interactive", "search", "--details", "--match-exact", "--type", "package"}, names...),
+	})
+	if err != nil && !runner.IsExitError(err) {
+		return nil, err
 	}
 
-	available := strings.Contains(string(stdout), "Information for package")
-	if available {
-		z.getPackageVersion(pkg, string(stdout))
+	installed, available, parseErr := z.parseSearch(stdout)
+	if parseErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, parseErr
 	}
 
-	return available, nil
+	return collectStatuses(pkgs, z.name, installed, available), nil
 }
 
-func (z *Zypper) getPackageVersion(pkg *Package, output string) {
-	reg := regexp.MustCompile(`.*Version.*:(.*)`)
-	matches := reg.FindStringSubmatch(output)
-	pkg.Version = ""
-	noOfMatches := len(matches)
-	if noOfMatches > 1 {
-		pkg.Version = strings.TrimSpace(matches[1])
+func (z *Zypper) PackageInstalled(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, z, pkg)
+	return status.Installed, err
+}
+
+func (z *Zypper) PackageAvailable(ctx context.Context, pkg *Package) (bool, error) {
+	status, err := queryPackage(ctx, z, pkg)
+	return status.Available, err
+}
+
+// parseSearch returns the installed editions and the newest edition offered
+// by a repository, which zypper lists first. Installed packages are listed a
+// second time for the "(System Packages)" pseudo repository.
+func (z *Zypper) parseSearch(output []byte) (map[string]string, map[string]string, error) {
+	var stream zypperStream
+	if err := xml.Unmarshal(output, &stream); err != nil {
+		return nil, nil, fmt.Errorf("invalid zypper output: %v", err)
+	}
+
+	installed := map[string]string{}
+	available := map[string]string{}
+	for _, solvable := range stream.Solvables {
+		if _, ok := installed[solvable.Name]; solvable.Status == "installed" && !ok {
+			installed[solvable.Name] = solvable.Edition
+		}
+		if _, ok := available[solvable.Name]; solvable.Repository != "(System Packages)" && !ok {
+			available[solvable.Name] = solvable.Edition
+		}
 	}
+	return installed, available, nil
 }
@@ -0,0 +1,25 @@
+//go:build linux
+
+package packagemanager
+
+import "testing"
+
+func TestZypperQueryPackages(t *testing.T) {
+	tests := map[string][]queryTest{
+		"zypper/opensuse-tumbleweed.json": {
+			{"git", "git", PackageStatus{true, true, "2.47.1-1.1", "2.47.1-2.1"}},
+			{"maven", "maven", PackageStatus{true, false, "3.9.9-1.2", "3.9.9-1.2"}},
+			{"vscode", "code", PackageStatus{false, false, "", ""}},
+		},
+		"zypper/opensuse-leap-15.6.json": {
+			{"vscode", "code", PackageStatus{false, false, "", ""}},
+		},
+	}
+
+	for fixture, queries := range tests {
+		t.Run(fixture, func(t *testing.T) {
+			fake := loadFixture(t, fixture)
+			checkQuery(t, NewZypper("opensuse-tumbleweed", fake), fake, queries, 1)
+		})
+	}
+}
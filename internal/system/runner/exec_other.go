@@ -0,0 +1,11 @@
+//go:build !unix
+
+package runner
+
+import "os/exec"
+
+// killProcessGroupOnCancel keeps the default behaviour of killing only the
+// started process on platforms without process groups.
+func killProcessGroupOnCancel(c *exec.Cmd) func() {
+	return func() {}
+}
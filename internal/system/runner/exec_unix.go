@@ -0,0 +1,32 @@
+//go:build unix
+
+package runner
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// killProcessGroupOnCancel starts the command in its own process group so
+// that cancelling it also reaches children like the package manager started
+// by sudo. The returned function must be called once the command has exited.
+func killProcessGroupOnCancel(c *exec.Cmd) func() {
+	var timer *time.Timer
+
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Cancel = func() error {
+		pgid := c.Process.Pid
+		timer = time.AfterFunc(killGracePeriod, func() {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		return syscall.Kill(-pgid, syscall.SIGTERM)
+	}
+	c.WaitDelay = 2 * killGracePeriod
+
+	return func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
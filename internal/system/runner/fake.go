@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// Response is the recorded result of a single command invocation.
+type Response struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// Fake is a Runner that replays recorded responses instead of executing
+// anything. Responses are keyed by the command line as rendered by
+// Command.String.
+type Fake struct {
+	Responses map[string]Response
+	Calls     []Command
+	// Paths maps the commands LookPath finds to their paths.
+	Paths map[string]string
+
+	mu sync.Mutex
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		Responses: map[string]Response{},
+		Paths:     map[string]string{},
+	}
+}
+
+// LoadFake reads a JSON fixture mapping command lines to responses.
+func LoadFake(path string) (*Fake, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := NewFake()
+	if err := json.Unmarshal(data, &f.Responses); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %v", path, err)
+	}
+	return f, nil
+}
+
+func (f *Fake) Add(cmdline string, resp Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Responses[cmdline] = resp
+}
+
+// LookPath finds only the commands in Paths.
+func (f *Fake) LookPath(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if path, ok := f.Paths[name]; ok {
+		return path, nil
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+func (f *Fake) Run(ctx context.Context, cmd Command, stdin io.Reader, stdout, stderr io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.Calls = append(f.Calls, cmd)
+	resp, ok := f.Responses[cmd.String()]
+	f.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no recorded response for %q", cmd.String())
+	}
+	if stdout != nil {
+		io.WriteString(stdout, resp.Stdout)
+	}
+	if stderr != nil {
+		io.WriteString(stderr, resp.Stderr)
+	}
+	if resp.ExitCode != 0 {
+		return &ExitError{Code: resp.ExitCode}
+	}
+	return nil
+}
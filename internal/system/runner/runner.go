@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// killGracePeriod is how long a cancelled command gets to exit before it is
+// killed.
+const killGracePeriod = 5 * time.Second
+
+// Command describes a single invocation of an external program. Env holds
+// additional variables that are appended to the current environment. Elevate
+// marks commands that need root privileges; a Runner never elevates on its
+// own, the caller has to wrap such a command first. Interactive marks commands
+// that may ask the user on the terminal themselves, like doas asking for the
+// password. They stay in the foreground process group of the terminal, so
+// cancelling them only stops the started process.
+type Command struct {
+	Name        string
+	Args        []string
+	Env         []string
+	Elevate     bool
+	Interactive bool
+}
+
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Shell renders the command the way it would be typed into a shell, with
+// arguments quoted where necessary. It is meant for showing the command to the
+// user, commands are always executed without a shell.
+func (c Command) Shell() string {
+	var words []string
+	for _, env := range c.Env {
+		words = append(words, quote(env))
+	}
+	words = append(words, quote(c.Name))
+	for _, arg := range c.Args {
+		words = append(words, quote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+func quote(word string) string {
+	if word != "" && strings.Trim(word, safeChars) == "" {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+const safeChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-"
+
+// Runner executes commands on behalf of the package manager backends and the
+// platform manager. When ctx is done the command is stopped and ctx.Err() is
+// returned.
+type Runner interface {
+	Run(ctx context.Context, cmd Command, stdin io.Reader, stdout, stderr io.Writer) error
+	// LookPath returns the path of the command called name, or an error if
+	// Run would not find it.
+	LookPath(name string) (string, error)
+}
+
+// ExitError is returned by a Runner when the command ran but exited with a
+// non-zero status.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func IsExitError(err error) bool {
+	var exitErr *ExitError
+	return errors.As(err, &exitErr)
+}
+
+// Output runs cmd and returns its captured stdout and stderr.
+func Output(ctx context.Context, r Runner, cmd Command) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	err := r.Run(ctx, cmd, nil, &stdout, &stderr)
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+type ExecRunner struct{}
+
+func NewExecRunner() *ExecRunner {
+	return &ExecRunner{}
+}
+
+func (e *ExecRunner) Run(ctx context.Context, cmd Command, stdin io.Reader, stdout, stderr io.Writer) error {
+	c := exec.CommandContext(ctx, cmd.Name, cmd.Args...)
+	if len(cmd.Env) > 0 {
+		c.Env = append(os.Environ(), cmd.Env...)
+	}
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	stop := func() {}
+	if !cmd.Interactive {
+		stop = killProcessGroupOnCancel(c)
+	}
+
+	err := c.Run()
+	stop()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+func (e *ExecRunner) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
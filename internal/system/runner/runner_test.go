@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecRunnerExitError(t *testing.T) {
+	_, _, err := Output(context.Background(), NewExecRunner(), Command{Name: "sh", Args: []string{"-c", "exit 3"}})
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("Output() error = %v, want exit status 3", err)
+	}
+}
+
+func TestExecRunnerEnv(t *testing.T) {
+	stdout, _, err := Output(context.Background(), NewExecRunner(), Command{
+		Name: "sh",
+		Args: []string{"-c", "echo $JWS_TEST"},
+		Env:  []string{"JWS_TEST=fixture"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(stdout)); got != "fixture" {
+		t.Errorf("stdout = %q, want %q", got, "fixture")
+	}
+}
+
+func TestExecRunnerCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := Output(ctx, NewExecRunner(), Command{Name: "sh", Args: []string{"-c", "sleep 30; echo done"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Output() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > killGracePeriod {
+		t.Errorf("process group was not stopped on cancel, took %v", elapsed)
+	}
+}
+
+func TestFakeReplaysResponses(t *testing.T) {
+	fake := NewFake()
+	fake.Add("pacman -Q podman", Response{Stderr: "error: package 'podman' was not found\n", ExitCode: 1})
+
+	_, stderr, err := Output(context.Background(), fake, Command{Name: "pacman", Args: []string{"-Q", "podman"}})
+	if !IsExitError(err) {
+		t.Errorf("Output() error = %v, want exit error", err)
+	}
+	if !strings.Contains(string(stderr), "was not found") {
+		t.Errorf("stderr = %q", stderr)
+	}
+
+	if _, _, err := Output(context.Background(), fake, Command{Name: "pacman", Args: []string{"-Q", "git"}}); err == nil || IsExitError(err) {
+		t.Errorf("Output() error = %v, want missing recording error", err)
+	}
+	if len(fake.Calls) != 2 {
+		t.Errorf("len(Calls) = %d, want 2", len(fake.Calls))
+	}
+}
+
+func TestCommandShell(t *testing.T) {
+	tests := []struct {
+		cmd  Command
+		want string
+	}{
+		{Command{Name: "apt", Args: []string{"install", "git", "-y"}}, "apt install git -y"},
+		{Command{Name: "dpkg-query", Args: []string{"-W", "--showformat=${Package}\n"}}, "dpkg-query -W '--showformat=${Package}\n'"},
+		{Command{Name: "echo", Args: []string{"it's", ""}}, `echo 'it'\''s' ''`},
+		{Command{Name: "apt-cache", Args: []string{"policy"}, Env: []string{"LC_ALL=C"}}, "LC_ALL=C apt-cache policy"},
+		{Command{Name: "sh", Args: []string{"-c", "rm -rf ~; true"}}, "sh -c 'rm -rf ~; true'"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cmd.Shell(); got != tt.want {
+			t.Errorf("Shell() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package version
+
+import "strings"
+
+// apkSuffixes ranks the suffixes of Alpine versions. A version without a
+// suffix ranks between _rc and _cvs.
+var apkSuffixes = map[string]int{
+	"alpha": -4,
+	"beta":  -3,
+	"pre":   -2,
+	"rc":    -1,
+	"cvs":   1,
+	"svn":   2,
+	"git":   3,
+	"hg":    4,
+	"p":     5,
+}
+
+// apkVersion is a version like "17.0.13_p11-r0": dotted numbers, an optional
+// letter, suffixes with optional numbers and the package release.
+type apkVersion struct {
+	numbers  []string
+	letter   byte
+	suffixes []apkSuffix
+	release  string
+}
+
+type apkSuffix struct {
+	rank   int
+	number string
+}
+
+func parseApk(s string) apkVersion {
+	var v apkVersion
+	if i := strings.LastIndex(s, "-r"); i >= 0 {
+		s, v.release = s[:i], s[i+2:]
+	}
+
+	head, tail, _ := strings.Cut(s, "_")
+	if n := len(head); n > 0 && isAlpha(head[n-1]) {
+		head, v.letter = head[:n-1], head[n-1]
+	}
+	v.numbers = strings.Split(head, ".")
+
+	if tail != "" {
+		for _, suffix := range strings.Split(tail, "_") {
+			i := strings.IndexFunc(suffix, func(r rune) bool { return r >= '0' && r <= '9' })
+			if i < 0 {
+				i = len(suffix)
+			}
+			v.suffixes = append(v.suffixes, apkSuffix{apkSuffixes[suffix[:i]], suffix[i:]})
+		}
+	}
+	return v
+}
+
+// compareApk orders versions like apk-tools: numbers first, a missing number
+// is older, then the letter, the suffixes and finally the release.
+func compareApk(a, b string) int {
+	v1, v2 := parseApk(a), parseApk(b)
+
+	for i := 0; i < len(v1.numbers) || i < len(v2.numbers); i++ {
+		switch {
+		case i >= len(v1.numbers):
+			return -1
+		case i >= len(v2.numbers):
+			return 1
+		}
+		if c := compareEpoch(v1.numbers[i], v2.numbers[i]); c != 0 {
+			return c
+		}
+	}
+
+	if v1.letter != v2.letter {
+		return sign(int(v1.letter) - int(v2.letter))
+	}
+
+	for i := 0; i < len(v1.suffixes) || i < len(v2.suffixes); i++ {
+		var s1, s2 apkSuffix
+		if i < len(v1.suffixes) {
+			s1 = v1.suffixes[i]
+		}
+		if i < len(v2.suffixes) {
+			s2 = v2.suffixes[i]
+		}
+		if s1.rank != s2.rank {
+			return sign(s1.rank - s2.rank)
+		}
+		if c := compareEpoch(s1.number, s2.number); c != 0 {
+			return c
+		}
+	}
+
+	return compareEpoch(v1.release, v2.release)
+}
@@ -0,0 +1,85 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+var operators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+type clause struct {
+	op      string
+	version string
+}
+
+// Constraint is a list of comparisons that must all hold, written like
+// ">= 17" or ">= 3.8, < 4".
+type Constraint struct {
+	clauses []clause
+}
+
+// ParseConstraint parses a comma separated list of comparisons. A version
+// without an operator has to match exactly.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("invalid version constraint %q: empty comparison", s)
+		}
+
+		op := "="
+		for _, candidate := range operators {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				part = strings.TrimSpace(part[len(candidate):])
+				break
+			}
+		}
+		if op == "==" {
+			op = "="
+		}
+		if part == "" || strings.ContainsAny(part, " \t<>=!") {
+			return nil, fmt.Errorf("invalid version constraint %q: bad version %q", s, part)
+		}
+
+		c.clauses = append(c.clauses, clause{op: op, version: part})
+	}
+	return c, nil
+}
+
+// Check reports whether an installed version satisfies the constraint under
+// the rules of scheme. Parts the constraint leaves out, like an epoch or a
+// release, are ignored.
+func (c *Constraint) Check(scheme Scheme, installed string) bool {
+	for _, cl := range c.clauses {
+		cmp := scheme.match(installed, cl.version)
+		var ok bool
+		switch cl.op {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		case "!=":
+			ok = cmp != 0
+		default:
+			ok = cmp == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Constraint) String() string {
+	parts := make([]string, len(c.clauses))
+	for i, cl := range c.clauses {
+		parts[i] = cl.op + " " + cl.version
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,72 @@
+package version
+
+import "testing"
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{">= 17", ">= 17"},
+		{">=3.8", ">= 3.8"},
+		{">= 3.8, < 4", ">= 3.8, < 4"},
+		{"== 1:2.39", "= 1:2.39"},
+		{"17", "= 17"},
+		{"!=2.0-1", "!= 2.0-1"},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.input)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got := c.String(); got != tt.want {
+			t.Errorf("ParseConstraint(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", ">=", ">= 1 2", ">= 1,", "=> 1", "<>1"} {
+		if _, err := ParseConstraint(input); err == nil {
+			t.Errorf("ParseConstraint(%q) succeeded, want error", input)
+		}
+	}
+}
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		scheme     Scheme
+		constraint string
+		installed  string
+		want       bool
+	}{
+		{Debian, ">= 17", "17.0.13+11-2~deb12u1", true},
+		{Debian, ">= 17, < 18", "17.0.13+11-2~deb12u1", true},
+		{Debian, ">= 21", "17.0.13+11-2~deb12u1", false},
+		{Debian, ">= 2.40", "1:2.39.5-0+deb12u1", false},
+		{Debian, ">= 1:2.39", "1:2.39.5-0+deb12u1", true},
+		{Debian, ">= 2:2.0", "1:2.39.5-0+deb12u1", false},
+		{Debian, ">= 3.8", "3.6.3-5", false},
+		{RPM, ">= 17, < 18", "1:17.0.13.0.11-1.fc41", true},
+		{RPM, "= 2.47.1", "2.47.1-2.fc41", true},
+		{RPM, "= 2.47.1-1.fc41", "2.47.1-2.fc41", false},
+		{Pacman, ">= 17", "17.0.13.u11-1", true},
+		{Pacman, "> 5.3.1", "5.3.1-1", false},
+		{Apk, ">= 17", "17.0.13_p11-r0", true},
+		{Apk, "= 2.47.1", "2.47.1-r0", true},
+		{Apk, "> 5.3.1", "5.3.1-r0", false},
+		{Nix, ">= 3.8", "3.9.9", true},
+		{Nix, "< 3.10", "3.9.9", true},
+		{Generic, "!= 17.0.2", "17.0.2", false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Check(tt.scheme, tt.installed); got != tt.want {
+			t.Errorf("%s: %q.Check(%q) = %v, want %v", tt.scheme, tt.constraint, tt.installed, got, tt.want)
+		}
+	}
+}
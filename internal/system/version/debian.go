@@ -0,0 +1,74 @@
+package version
+
+// compareDebian orders versions like dpkg --compare-versions: the epoch is
+// compared numerically, upstream version and revision with verrevcmp.
+func compareDebian(a, b string) int {
+	e1, v1, r1 := splitEVR(a)
+	e2, v2, r2 := splitEVR(b)
+
+	if c := compareEpoch(e1, e2); c != 0 {
+		return c
+	}
+	if c := verrevcmp(v1, v2); c != 0 {
+		return c
+	}
+	return verrevcmp(r1, r2)
+}
+
+// order is the weight of a single character in a non-digit part. A tilde
+// sorts before everything, even the end of the string.
+func order(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -1
+	}
+	return int(c) + 256
+}
+
+// verrevcmp is the comparison of dpkg, which alternates between non-digit
+// parts compared character by character and digit parts compared numerically.
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		firstDiff := 0
+		for i < len(a) && !isDigit(a[i]) || j < len(b) && !isDigit(b[j]) {
+			ac, bc := order(a, i), order(b, j)
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
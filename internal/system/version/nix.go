@@ -0,0 +1,61 @@
+package version
+
+import "strconv"
+
+// compareNix orders versions like builtins.compareVersions. Versions are split
+// into components at dots and dashes and wherever digits and letters meet.
+func compareNix(a, b string) int {
+	for a != "" || b != "" {
+		var c1, c2 string
+		c1, a = nextComponent(a)
+		c2, b = nextComponent(b)
+		if componentLess(c1, c2) {
+			return -1
+		}
+		if componentLess(c2, c1) {
+			return 1
+		}
+	}
+	return 0
+}
+
+func nextComponent(s string) (string, string) {
+	for s != "" && (s[0] == '.' || s[0] == '-') {
+		s = s[1:]
+	}
+	if s == "" {
+		return "", ""
+	}
+
+	i := 1
+	digits := isDigit(s[0])
+	for i < len(s) && s[i] != '.' && s[i] != '-' && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// componentLess implements the rules of Nix: numbers compare numerically, a
+// missing component is older than a number, "pre" is older than anything
+// else and letters are older than numbers.
+func componentLess(c1, c2 string) bool {
+	n1, err1 := strconv.Atoi(c1)
+	n2, err2 := strconv.Atoi(c2)
+	isNum1, isNum2 := err1 == nil, err2 == nil
+
+	switch {
+	case isNum1 && isNum2:
+		return n1 < n2
+	case c1 == "" && isNum2:
+		return true
+	case c1 == "pre" && c2 != "pre":
+		return true
+	case c2 == "pre":
+		return false
+	case isNum2:
+		return true
+	case isNum1:
+		return false
+	}
+	return c1 < c2
+}
@@ -0,0 +1,166 @@
+package version
+
+import "strings"
+
+// compareEVR compares epoch, version and release with cmp. Like rpm and
+// libalpm, the release is only compared when both versions have one.
+func compareEVR(a, b string, cmp func(a, b string) int) int {
+	e1, v1, r1 := splitEVR(a)
+	e2, v2, r2 := splitEVR(b)
+
+	if c := compareEpoch(e1, e2); c != 0 {
+		return c
+	}
+	if c := cmp(v1, v2); c != 0 {
+		return c
+	}
+	if r1 == "" || r2 == "" {
+		return 0
+	}
+	return cmp(r1, r2)
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || isAlpha(c)
+}
+
+func isSeparator(r rune) bool {
+	return r >= 128 || !isAlnum(byte(r))
+}
+
+func isRPMSeparator(r rune) bool {
+	return isSeparator(r) && r != '~' && r != '^'
+}
+
+// segment returns the run of digits or letters at the start of s.
+func segment(s string, digits bool) string {
+	i := 0
+	for i < len(s) && (digits && isDigit(s[i]) || !digits && isAlpha(s[i])) {
+		i++
+	}
+	return s[:i]
+}
+
+// compareSegments compares two digit or letter segments.
+func compareSegments(a, b string, digits bool) int {
+	if digits {
+		a = strings.TrimLeft(a, "0")
+		b = strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			return sign(len(a) - len(b))
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// rpmvercmp is the comparison of rpm, including the tilde for pre-releases
+// and the caret for snapshots after a release.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for len(a) > 0 || len(b) > 0 {
+		a = strings.TrimLeftFunc(a, isRPMSeparator)
+		b = strings.TrimLeftFunc(b, isRPMSeparator)
+
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+			if b == "" {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		digits := isDigit(a[0])
+		s1, s2 := segment(a, digits), segment(b, digits)
+		if s1 == "" {
+			return -1
+		}
+		if s2 == "" {
+			if digits {
+				return 1
+			}
+			return -1
+		}
+		if c := compareSegments(s1, s2, digits); c != 0 {
+			return c
+		}
+		a, b = a[len(s1):], b[len(s2):]
+	}
+
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a != "":
+		return 1
+	}
+	return -1
+}
+
+// alpmvercmp is the comparison of libalpm, which pacman and vercmp use. It is
+// derived from an older rpmvercmp without tilde and caret, but lets a
+// remaining letter segment lose against the end of the string.
+func alpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for a != "" && b != "" {
+		sep1 := len(a) - len(strings.TrimLeftFunc(a, isSeparator))
+		sep2 := len(b) - len(strings.TrimLeftFunc(b, isSeparator))
+		a, b = a[sep1:], b[sep2:]
+
+		if a == "" || b == "" {
+			break
+		}
+		if sep1 != sep2 {
+			return sign(sep1 - sep2)
+		}
+
+		digits := isDigit(a[0])
+		s1, s2 := segment(a, digits), segment(b, digits)
+		if s2 == "" {
+			if digits {
+				return 1
+			}
+			return -1
+		}
+		if c := compareSegments(s1, s2, digits); c != 0 {
+			return c
+		}
+		a, b = a[len(s1):], b[len(s2):]
+	}
+
+	if a == "" && b == "" {
+		return 0
+	}
+	if a == "" && !isAlpha(b[0]) || a != "" && isAlpha(a[0]) {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,124 @@
+package version
+
+import "strings"
+
+// Scheme selects the rules used to order the version strings of one package
+// ecosystem.
+type Scheme int
+
+const (
+	// Generic orders dotted versions like Nix does. It is used by backends
+	// without stricter rules of their own, such as Homebrew and Chocolatey.
+	Generic Scheme = iota
+	Debian
+	RPM
+	Pacman
+	Nix
+	Apk
+)
+
+func (s Scheme) String() string {
+	switch s {
+	case Debian:
+		return "debian"
+	case RPM:
+		return "rpm"
+	case Pacman:
+		return "pacman"
+	case Nix:
+		return "nix"
+	case Apk:
+		return "apk"
+	}
+	return "generic"
+}
+
+// Compare returns -1, 0 or +1 depending on whether a is older than, equal to
+// or newer than b.
+func (s Scheme) Compare(a, b string) int {
+	switch s {
+	case Debian:
+		return compareDebian(a, b)
+	case RPM:
+		return compareEVR(a, b, rpmvercmp)
+	case Pacman:
+		return compareEVR(a, b, alpmvercmp)
+	case Apk:
+		return compareApk(a, b)
+	}
+	return compareNix(a, b)
+}
+
+// match compares an installed version with the version of a constraint. Parts
+// the constraint leaves out, such as the epoch or the release, are not
+// compared, so ">= 17" matches "1:17.0.13-1.fc41".
+func (s Scheme) match(installed, wanted string) int {
+	switch s {
+	case Debian, RPM, Pacman:
+		e1, v1, r1 := splitEVR(installed)
+		e2, v2, r2 := splitEVR(wanted)
+		if !strings.Contains(wanted, ":") {
+			e1 = ""
+		}
+		if r2 == "" {
+			r1 = ""
+		}
+		return s.Compare(joinEVR(e1, v1, r1), joinEVR(e2, v2, r2))
+	case Apk:
+		if !strings.Contains(wanted, "-r") {
+			installed, _, _ = strings.Cut(installed, "-r")
+		}
+	}
+	return s.Compare(installed, wanted)
+}
+
+// splitEVR splits a version into epoch, version and release, which Debian
+// calls the revision.
+func splitEVR(evr string) (epoch, version, release string) {
+	version = evr
+	if i := strings.IndexByte(version, ':'); i >= 0 {
+		epoch, version = version[:i], version[i+1:]
+	}
+	if i := strings.LastIndexByte(version, '-'); i >= 0 {
+		version, release = version[:i], version[i+1:]
+	}
+	return epoch, version, release
+}
+
+func joinEVR(epoch, version, release string) string {
+	if epoch != "" {
+		version = epoch + ":" + version
+	}
+	if release != "" {
+		version += "-" + release
+	}
+	return version
+}
+
+// compareEpoch compares two numeric epochs, a missing epoch counts as 0.
+func compareEpoch(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,88 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		scheme Scheme
+		a, b   string
+		want   int
+	}{
+		{Debian, "1.0", "1.0", 0},
+		{Debian, "1.0", "1.1", -1},
+		{Debian, "1.0.0", "1.0", 1},
+		{Debian, "1.0~rc1", "1.0", -1},
+		{Debian, "1.0~rc1", "1.0~rc2", -1},
+		{Debian, "1.0-1~bpo12", "1.0-1", -1},
+		{Debian, "1.0+b1", "1.0", 1},
+		{Debian, "1.0a", "1.0", 1},
+		{Debian, "1.0a", "1.0+", -1},
+		{Debian, "0:1.0", "1.0", 0},
+		{Debian, "1:0.9", "2.0", 1},
+		{Debian, "1.0-1", "1.0-2", -1},
+		{Debian, "1:2.39.5-0+deb12u1", "1:2.39.5-0+deb12u2", -1},
+		{Debian, "17.0.13+11-2~deb12u1", "17.0.13+11-2", -1},
+		{Debian, "10.0001", "10.1", 0},
+
+		{RPM, "1.0", "2.0", -1},
+		{RPM, "2.0.1", "2.0", 1},
+		{RPM, "2.0.1a", "2.0.1", 1},
+		{RPM, "5.5p1", "5.5p10", -1},
+		{RPM, "10xyz", "10.1xyz", -1},
+		{RPM, "xyz10", "xyz10.1", -1},
+		{RPM, "1.0aa", "1.0a", 1},
+		{RPM, "a", "1", -1},
+		{RPM, "2_0", "2.0", 0},
+		{RPM, "10.0001", "10.1", 0},
+		{RPM, "1.0~rc1", "1.0", -1},
+		{RPM, "1.0~rc1", "1.0~rc2", -1},
+		{RPM, "1.0^", "1.0", 1},
+		{RPM, "1.0^git1", "1.01", -1},
+		{RPM, "1.0^git1~pre", "1.0^git1", -1},
+		{RPM, "1:17.0.13.0.11-1.fc41", "17.0.14-1.fc41", 1},
+		{RPM, "2.47.1-1.fc41", "2.47.1-2.fc41", -1},
+		{RPM, "2.47.1-1.fc41", "2.47.1", 0},
+
+		{Pacman, "1.5.0", "1.5.0", 0},
+		{Pacman, "1.5.1", "1.5.0", 1},
+		{Pacman, "1.0", "1.0.0", -1},
+		{Pacman, "1.5b", "1.5", -1},
+		{Pacman, "1.5rc1", "1.5.1", -1},
+		{Pacman, "1.0.a", "1.0.1", -1},
+		{Pacman, "1..0", "1.1", 1},
+		{Pacman, "1:1.0", "2.0", 1},
+		{Pacman, "1.0-1", "1.0-2", -1},
+		{Pacman, "1.0-2", "1.0", 0},
+		{Pacman, "17.0.13.u11-1", "17.0.12.u7-1", 1},
+
+		{Nix, "1.0", "2.3", -1},
+		{Nix, "2.3", "2.3a", -1},
+		{Nix, "2.3a", "2.3.1", -1},
+		{Nix, "2.3pre1", "2.3", -1},
+		{Nix, "2.4pre", "2.4", -1},
+		{Nix, "2.3.1", "2.3.1", 0},
+		{Nix, "3.9.9", "3.10", -1},
+		{Nix, "2.47.0", "2.47.0-rc0", -1},
+
+		{Apk, "1.0", "1.0", 0},
+		{Apk, "1.0", "1.0.1", -1},
+		{Apk, "1.0a", "1.0", 1},
+		{Apk, "1.0_rc1", "1.0", -1},
+		{Apk, "1.0_alpha2", "1.0_beta1", -1},
+		{Apk, "1.0_p1", "1.0", 1},
+		{Apk, "1.0-r1", "1.0-r10", -1},
+		{Apk, "2.47.1-r0", "2.47.1", 0},
+		{Apk, "17.0.13_p11-r0", "17.0.12_p7-r0", 1},
+
+		{Generic, "17.0.13", "17.0.2", 1},
+	}
+
+	for _, tt := range tests {
+		if got := tt.scheme.Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s.Compare(%q, %q) = %d, want %d", tt.scheme, tt.a, tt.b, got, tt.want)
+		}
+		if got := tt.scheme.Compare(tt.b, tt.a); got != -tt.want {
+			t.Errorf("%s.Compare(%q, %q) = %d, want %d", tt.scheme, tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
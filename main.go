@@ -1,22 +1,21 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
 
 	"github.com/PatrykHegenberg/jws_gui/internal/cli"
-	"github.com/PatrykHegenberg/jws_gui/internal/gui"
-	"github.com/PatrykHegenberg/jws_gui/internal/platform"
 )
 
 func main() {
-	pm := platform.NewPlatformManager()
+	// The first Ctrl+C cancels the running operation, a second one
+	// terminates the process as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	context.AfterFunc(ctx, stop)
 
-	if len(os.Args) > 1 {
-		if err := cli.SetupCLI(pm).Execute(); err != nil {
-			log.Fatal(err)
-		}
-	} else {
-		gui.SetupGUI(pm)
+	if err := cli.SetupCLI().ExecuteContext(ctx); err != nil {
+		log.Fatal(err)
 	}
 }